@@ -0,0 +1,239 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// backupSuffix is the suffix of backup files.
+	backupSuffix = ".bak"
+
+	// backupTimeFormat is the format of the timestamps in the names of backup
+	// files, it can be sorted lexically.
+	backupTimeFormat = "20060102T150405.000000000"
+)
+
+// FileBackup configures the backups of files replaced or removed by a file
+// provider. Backups are named after the original file, with a timestamp and
+// the ".bak" suffix, as in "config.yml.20240102T150405.000000000.bak".
+type FileBackup struct {
+	// Dir is the directory where backups are stored, keeping the same structure
+	// of directories as the original files. It is a path in the file system of
+	// the provider, so it is relative to its prefix. If not set, backups are stored
+	// in the same directory as the original files.
+	Dir string
+
+	// Retention is the number of backups to keep for each file, older backups
+	// are removed. If zero, all backups are kept.
+	Retention int
+}
+
+// backup copies the file in the given path of the provider to a new backup file,
+// and removes old backups exceeding the retention. The complete path of the backup
+// is recorded in the context. It doesn't do anything if backups are not enabled,
+// or if the file doesn't exist.
+func (p *FileProvider) backup(ctx context.Context, path string) error {
+	if p.Backup == nil {
+		return nil
+	}
+
+	fsys := p.fs()
+	info, err := fsys.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if p.Backup.Dir != "" {
+		dir = filepath.Join(p.Backup.Dir, filepath.Dir(filepath.Join("/", path)))
+		if err := fsys.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+	}
+
+	name := filepath.Base(path)
+	target := filepath.Join(dir, name+"."+time.Now().UTC().Format(backupTimeFormat)+backupSuffix)
+	if err := copyFile(fsys, path, target, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to backup %s: %w", p.path(path), err)
+	}
+	recordBackup(ctx, p.path(target))
+
+	if p.Backup.Retention > 0 {
+		if err := removeOldBackups(fsys, dir, name, p.Backup.Retention); err != nil {
+			return fmt.Errorf("failed to remove old backups of %s: %w", p.path(path), err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies a file with the given permissions.
+func copyFile(fsys WritableFS, source, target string, perm fs.FileMode) error {
+	r, err := fsys.Open(source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := fsys.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// removeOldBackups removes the oldest backups of the file with the given name,
+// keeping the given number of backups.
+func removeOldBackups(fsys WritableFS, dir, name string, retention int) error {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, entry := range entries {
+		if original, found := backupOriginal(entry.Name()); found && original == name {
+			backups = append(backups, entry.Name())
+		}
+	}
+	if len(backups) <= retention {
+		return nil
+	}
+	slices.Sort(backups)
+	for _, backup := range backups[:len(backups)-retention] {
+		if err := fsys.Remove(filepath.Join(dir, backup)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupOriginal returns the name of the original file of a backup, and true
+// if the name is the name of a backup.
+func backupOriginal(name string) (string, bool) {
+	name, found := strings.CutSuffix(name, backupSuffix)
+	if !found {
+		return "", false
+	}
+	sep := len(name) - len(backupTimeFormat) - 1
+	if sep < 1 || name[sep] != '.' {
+		return "", false
+	}
+	if _, err := time.Parse(backupTimeFormat, name[sep+1:]); err != nil {
+		return "", false
+	}
+	return name[:sep], true
+}
+
+// sameContent returns true if both files have the same content.
+func sameContent(fsys WritableFS, a, b string) (bool, error) {
+	infoA, err := fsys.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := fsys.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	fa, err := fsys.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := fsys.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	const chunkSize = 32 * 1024
+	bufA, bufB := make([]byte, chunkSize), make([]byte, chunkSize)
+	for {
+		nA, errA := io.ReadFull(fa, bufA)
+		nB, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+type backupsRecorderKey struct{}
+
+// backupsRecorder keeps the paths of the backups made while applying a resource.
+type backupsRecorder struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// withBackupsRecorder returns a context where backups made can be recorded.
+func withBackupsRecorder(ctx context.Context) (context.Context, *backupsRecorder) {
+	recorder := &backupsRecorder{}
+	return context.WithValue(ctx, backupsRecorderKey{}, recorder), recorder
+}
+
+// recordBackup records the path of a backup in the recorder of the context, if any.
+func recordBackup(ctx context.Context, path string) {
+	recorder, ok := ctx.Value(backupsRecorderKey{}).(*backupsRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.paths = append(recorder.paths, path)
+}
+
+// Paths returns the paths of the backups recorded.
+func (r *backupsRecorder) Paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.paths)
+}
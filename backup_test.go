@@ -0,0 +1,364 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBackupOnUpdate(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Backup: &FileBackup{},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	path := filepath.Join(provider.Prefix, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("old content"), 0644))
+
+	resource := File{
+		Provider: providerName,
+		Path:     "/config.txt",
+		Content:  FileContentLiteral("new content"),
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, ActionUpdate, result[0].Action())
+
+	backups := result[0].Backups()
+	require.Len(t, backups, 1)
+	assert.Equal(t, provider.Prefix, filepath.Dir(backups[0]))
+	assert.True(t, strings.HasPrefix(filepath.Base(backups[0]), "config.txt."))
+	assert.True(t, strings.HasSuffix(backups[0], backupSuffix))
+
+	d, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "old content", string(d))
+
+	d, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new content", string(d))
+
+	// Applying again doesn't make new backups.
+	result, err = manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	entries, err := os.ReadDir(provider.Prefix)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestFileBackupOnModeUpdate(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Backup: &FileBackup{},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	path := filepath.Join(provider.Prefix, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+
+	mode := fs.FileMode(0600)
+	resource := File{
+		Provider: providerName,
+		Path:     "/config.txt",
+		Mode:     &mode,
+		Content:  FileContentLiteral("content"),
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Equal(t, ActionUpdate, result[0].Action())
+	assert.Empty(t, result[0].Backups(), "content is not replaced")
+}
+
+func TestFileBackupOnRemove(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Backup: &FileBackup{Dir: "backups"},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(provider.Prefix, "etc"), 0755))
+	path := filepath.Join(provider.Prefix, "etc", "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+
+	resource := File{
+		Provider: providerName,
+		Path:     "/etc/config.txt",
+		Absent:   true,
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Equal(t, ActionUpdate, result[0].Action())
+	assert.NoFileExists(t, path)
+
+	backups := result[0].Backups()
+	require.Len(t, backups, 1)
+	assert.Equal(t, filepath.Join(provider.Prefix, "backups", "etc"), filepath.Dir(backups[0]))
+
+	d, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(d))
+}
+
+func TestFileBackupRetention(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Backup: &FileBackup{Retention: 2},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	path := filepath.Join(provider.Prefix, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content 0"), 0644))
+
+	// Unrelated files are kept.
+	other := filepath.Join(provider.Prefix, "config.txt.other.bak")
+	require.NoError(t, os.WriteFile(other, []byte("other"), 0644))
+
+	var backups []string
+	for _, content := range []string{"content 1", "content 2", "content 3"} {
+		resource := File{
+			Provider: providerName,
+			Path:     "/config.txt",
+			Content:  FileContentLiteral(content),
+		}
+		result, err := manager.Apply(Resources{&resource})
+		require.NoError(t, err)
+		require.Len(t, result[0].Backups(), 1)
+		backups = append(backups, result[0].Backups()...)
+	}
+
+	assert.NoFileExists(t, backups[0])
+	assert.FileExists(t, backups[1])
+	assert.FileExists(t, backups[2])
+	assert.FileExists(t, other)
+
+	d, err := os.ReadFile(backups[2])
+	require.NoError(t, err)
+	assert.Equal(t, "content 2", string(d))
+}
+
+func TestFileNoBackup(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	path := filepath.Join(provider.Prefix, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("old content"), 0644))
+
+	resource := File{
+		Provider: providerName,
+		Path:     "/config.txt",
+		Content:  FileContentLiteral("new content"),
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Empty(t, result[0].Backups())
+
+	entries, err := os.ReadDir(provider.Prefix)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestSameContent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	large := strings.Repeat("a", 100*1024)
+
+	cases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"", "", true},
+		{"content", "content", true},
+		{"content", "contents", false},
+		{"content", "CONTENT", false},
+		{large, large, true},
+		{large, large[:len(large)-1] + "b", false},
+	}
+	for i, c := range cases {
+		a := write("a", c.a)
+		b := write("b", c.b)
+		same, err := sameContent(osFS{}, a, b)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, same, "case %d", i)
+	}
+}
+
+func TestDirectoryPurgeKeepsBackups(t *testing.T) {
+	fsys := &MemFS{}
+	provider := FileProvider{
+		FS:     fsys,
+		Backup: &FileBackup{},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	require.NoError(t, fsys.MkdirAll("config", 0755))
+	require.NoError(t, writeFile(fsys, "config/a.txt", []byte("extra"), 0644))
+
+	resource := Directory{
+		Path:   "config",
+		Source: NewSourceFS(os.DirFS("testdata/directory/config")),
+		Purge:  true,
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	var backups []string
+	for _, r := range result {
+		backups = append(backups, r.Backups()...)
+	}
+	require.Len(t, backups, 1)
+
+	for i := 0; i < 3; i++ {
+		result, err = manager.Apply(Resources{&resource})
+		t.Log(result)
+		require.NoError(t, err)
+		assert.Empty(t, result)
+	}
+
+	entries, err := fsys.ReadDir("config")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		if _, found := backupOriginal(entry.Name()); found {
+			names = append(names, entry.Name())
+		}
+	}
+	assert.Equal(t, []string{filepath.Base(backups[0])}, names)
+}
+
+func TestBackupOriginal(t *testing.T) {
+	cases := []struct {
+		name     string
+		original string
+		found    bool
+	}{
+		{name: "config.txt.20240102T150405.000000000.bak", original: "config.txt", found: true},
+		{name: "config.txt.20240102T150405.000000000.bak.20240102T150406.000000000.bak", original: "config.txt.20240102T150405.000000000.bak", found: true},
+		{name: "config.txt"},
+		{name: "config.txt.bak"},
+		{name: "config.txt.other.bak"},
+		{name: ".20240102T150405.000000000.bak"},
+	}
+	for _, c := range cases {
+		original, found := backupOriginal(c.name)
+		assert.Equal(t, c.found, found, c.name)
+		assert.Equal(t, c.original, original, c.name)
+	}
+}
+
+func TestFileBackupOnForcedTypeChange(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Backup: &FileBackup{Dir: "backups"},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	path := filepath.Join(provider.Prefix, "config")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+
+	resource := File{
+		Path:      "/config",
+		Directory: true,
+		Force:     true,
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.DirExists(t, path)
+
+	backups := result[0].Backups()
+	require.Len(t, backups, 1)
+	d, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(d))
+}
+
+func TestSymlinkBackupOnForce(t *testing.T) {
+	cases := []struct {
+		title  string
+		absent bool
+	}{
+		{title: "replaced", absent: false},
+		{title: "removed", absent: true},
+	}
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			provider := FileProvider{
+				Prefix: t.TempDir(),
+				Backup: &FileBackup{Dir: "backups"},
+			}
+			manager := NewManager()
+			manager.RegisterProvider(defaultFileProviderName, &provider)
+
+			path := filepath.Join(provider.Prefix, "link")
+			require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+
+			resource := Symlink{
+				Path:   "/link",
+				Target: "target",
+				Absent: c.absent,
+				Force:  true,
+			}
+			result, err := manager.Apply(Resources{&resource})
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+
+			backups := result[0].Backups()
+			require.Len(t, backups, 1)
+			d, err := os.ReadFile(backups[0])
+			require.NoError(t, err)
+			assert.Equal(t, "content", string(d))
+
+			// Links are not backed up.
+			if !c.absent {
+				resource.Target = "other"
+				result, err = manager.Apply(Resources{&resource})
+				require.NoError(t, err)
+				require.Len(t, result, 1)
+				assert.Empty(t, result[0].Backups())
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Algorithms supported for checksums.
+const (
+	// ChecksumSHA256 is the SHA-256 algorithm.
+	ChecksumSHA256 = "sha256"
+
+	// ChecksumSHA512 is the SHA-512 algorithm.
+	ChecksumSHA512 = "sha512"
+
+	// ChecksumMD5 is the MD5 algorithm, supported for compatibility. Prefer
+	// other algorithms when possible.
+	ChecksumMD5 = "md5"
+)
+
+// Checksum is the expected digest of some content.
+type Checksum struct {
+	// Algorithm is the algorithm used to calculate the digest.
+	Algorithm string
+	// Hex is the hex-encoded value of the digest.
+	Hex string
+}
+
+// SHA256 returns a SHA-256 checksum with the given hex-encoded value.
+func SHA256(hex string) *Checksum {
+	return &Checksum{Algorithm: ChecksumSHA256, Hex: hex}
+}
+
+// SHA512 returns a SHA-512 checksum with the given hex-encoded value.
+func SHA512(hex string) *Checksum {
+	return &Checksum{Algorithm: ChecksumSHA512, Hex: hex}
+}
+
+// ParseChecksum parses a checksum in the form "<algorithm>:<hex value>", as
+// "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae".
+func ParseChecksum(s string) (*Checksum, error) {
+	algorithm, value, found := strings.Cut(s, ":")
+	if !found {
+		return nil, fmt.Errorf("invalid checksum %q, expected <algorithm>:<hex value>", s)
+	}
+	checksum := &Checksum{Algorithm: algorithm, Hex: value}
+	if err := checksum.validate(); err != nil {
+		return nil, err
+	}
+	return checksum, nil
+}
+
+// UnmarshalYAML decodes a checksum from a YAML string in the format accepted by
+// ParseChecksum.
+func (c *Checksum) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	checksum, err := ParseChecksum(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*c = *checksum
+	return nil
+}
+
+// String returns the string representation of the checksum, in the format
+// accepted by ParseChecksum.
+func (c *Checksum) String() string {
+	return c.Algorithm + ":" + c.Hex
+}
+
+// validate checks that the algorithm is supported and the value is correctly encoded.
+func (c *Checksum) validate() error {
+	h, err := newChecksumHash(c.Algorithm)
+	if err != nil {
+		return err
+	}
+	sum, err := hex.DecodeString(c.Hex)
+	if err != nil {
+		return fmt.Errorf("invalid %s checksum %q: %w", c.Algorithm, c.Hex, err)
+	}
+	if len(sum) != h.Size() {
+		return fmt.Errorf("invalid %s checksum %q: expected %d bytes, found %d", c.Algorithm, c.Hex, h.Size(), len(sum))
+	}
+	return nil
+}
+
+// newHash returns a hash to calculate digests with the algorithm of the checksum.
+func (c *Checksum) newHash() (hash.Hash, error) {
+	return newChecksumHash(c.Algorithm)
+}
+
+// verify returns an error if the digest calculated by the given hash doesn't
+// match the checksum.
+func (c *Checksum) verify(h hash.Hash) error {
+	expected, err := hex.DecodeString(c.Hex)
+	if err != nil {
+		return fmt.Errorf("invalid %s checksum %q: %w", c.Algorithm, c.Hex, err)
+	}
+	found := h.Sum(nil)
+	if !bytes.Equal(expected, found) {
+		return fmt.Errorf("%s checksum of content differs, expected %s, found %x", c.Algorithm, c.Hex, found)
+	}
+	return nil
+}
+
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumSHA512:
+		return sha512.New(), nil
+	case ChecksumMD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+}
+
+// md5Checksum returns the checksum for a deprecated MD5 value, that can be
+// hex-encoded or contain the raw bytes of the digest.
+func md5Checksum(value string) *Checksum {
+	checksum := &Checksum{Algorithm: ChecksumMD5, Hex: value}
+	if checksum.validate() != nil {
+		checksum.Hex = hex.EncodeToString([]byte(value))
+	}
+	return checksum
+}
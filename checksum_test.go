@@ -0,0 +1,78 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksum(t *testing.T) {
+	cases := []struct {
+		checksum string
+		valid    bool
+	}{
+		{"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", true},
+		{"sha256:2C26B46B68FFC68FF99B453C1D30413413422D706483BFA0F98A5E886266E7AE", true},
+		{"sha512:f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7", true},
+		{"md5:acbd18db4cc2f85cedef654fccc4a4d8", true},
+		{"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", false},
+		{"sha1:0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", false},
+		{"sha256:acbd18db4cc2f85cedef654fccc4a4d8", false},
+		{"sha256:not-hex", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.checksum, func(t *testing.T) {
+			checksum, err := ParseChecksum(c.checksum)
+			if !c.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.checksum, checksum.String())
+		})
+	}
+}
+
+func TestChecksumVerify(t *testing.T) {
+	content := []byte("foo")
+	sum := sha256.Sum256(content)
+	checksum := SHA256(hex.EncodeToString(sum[:]))
+
+	h, err := checksum.newHash()
+	require.NoError(t, err)
+	h.Write(content)
+	assert.NoError(t, checksum.verify(h))
+
+	h.Reset()
+	h.Write([]byte("bar"))
+	assert.Error(t, checksum.verify(h))
+}
+
+func TestMD5Checksum(t *testing.T) {
+	sum := md5.Sum([]byte("foo"))
+	expected := "md5:acbd18db4cc2f85cedef654fccc4a4d8"
+	assert.Equal(t, expected, md5Checksum(hex.EncodeToString(sum[:])).String())
+	assert.Equal(t, expected, md5Checksum(string(sum[:])).String())
+}
@@ -0,0 +1,141 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+)
+
+// Condition decides if a resource should be applied, based on the information
+// available in the scope, as its facts.
+type Condition func(Scope) (bool, error)
+
+// ConditionalResource is implemented by resources that are only applied when
+// some condition is met. Resources whose condition is not met are skipped.
+type ConditionalResource interface {
+	Resource
+
+	// Enabled returns true if the resource should be applied in the given scope.
+	Enabled(Scope) (bool, error)
+}
+
+// When wraps a resource so it is only applied when the condition is met.
+func When(condition Condition, resource Resource) Resource {
+	return &conditionalResource{
+		condition: condition,
+		resource:  resource,
+	}
+}
+
+// FactEquals is a condition that is met when a fact has the given value.
+func FactEquals(name, value string) Condition {
+	return func(scope Scope) (bool, error) {
+		v, found := scope.Fact(name)
+		return found && v == value, nil
+	}
+}
+
+// FactExists is a condition that is met when a fact is defined.
+func FactExists(name string) Condition {
+	return func(scope Scope) (bool, error) {
+		_, found := scope.Fact(name)
+		return found, nil
+	}
+}
+
+// Not is a condition that is met when the given condition is not met.
+func Not(condition Condition) Condition {
+	return func(scope Scope) (bool, error) {
+		met, err := condition(scope)
+		return !met, err
+	}
+}
+
+// All is a condition that is met when all the given conditions are met.
+func All(conditions ...Condition) Condition {
+	return func(scope Scope) (bool, error) {
+		for _, condition := range conditions {
+			met, err := condition(scope)
+			if err != nil || !met {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// Any is a condition that is met when any of the given conditions is met.
+func Any(conditions ...Condition) Condition {
+	return func(scope Scope) (bool, error) {
+		for _, condition := range conditions {
+			met, err := condition(scope)
+			if err != nil || met {
+				return met, err
+			}
+		}
+		return false, nil
+	}
+}
+
+// conditionalResource is a resource that is only applied when a condition is met.
+type conditionalResource struct {
+	condition Condition
+	resource  Resource
+}
+
+// Enabled returns true if the condition is met in the given scope, and the
+// wrapped resource is enabled too.
+func (r *conditionalResource) Enabled(scope Scope) (bool, error) {
+	met, err := r.condition(scope)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition for %s: %w", r.resource, err)
+	}
+	if !met {
+		return false, nil
+	}
+	if conditional, ok := asResource[ConditionalResource](r.resource); ok {
+		return conditional.Enabled(scope)
+	}
+	return true, nil
+}
+
+// Unwrap returns the wrapped resource.
+func (r *conditionalResource) Unwrap() Resource {
+	return r.resource
+}
+
+func (r *conditionalResource) String() string {
+	return fmt.Sprint(r.resource)
+}
+
+func (r *conditionalResource) Get(ctx context.Context, scope Scope) (ResourceState, error) {
+	current, err := r.resource.Get(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedResourceState{ResourceState: current, resource: r.resource}, nil
+}
+
+func (r *conditionalResource) Create(ctx context.Context, scope Scope) error {
+	return r.resource.Create(ctx, scope)
+}
+
+func (r *conditionalResource) Update(ctx context.Context, scope Scope) error {
+	return r.resource.Update(ctx, scope)
+}
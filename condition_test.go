@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditions(t *testing.T) {
+	scope := NewManager()
+	scope.AddFacter(StaticFacter{
+		"os":   "linux",
+		"arch": "amd64",
+	})
+
+	cases := []struct {
+		title     string
+		condition Condition
+		expected  bool
+	}{
+		{"fact equals", FactEquals("os", "linux"), true},
+		{"fact not equals", FactEquals("os", "windows"), false},
+		{"fact not found", FactEquals("foo", ""), false},
+		{"fact exists", FactExists("arch"), true},
+		{"fact doesn't exist", FactExists("foo"), false},
+		{"not", Not(FactExists("foo")), true},
+		{"all", All(FactEquals("os", "linux"), FactEquals("arch", "amd64")), true},
+		{"not all", All(FactEquals("os", "linux"), FactEquals("arch", "arm64")), false},
+		{"any", Any(FactEquals("os", "windows"), FactEquals("arch", "amd64")), true},
+		{"not any", Any(FactEquals("os", "windows"), FactEquals("arch", "arm64")), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			met, err := c.condition(scope)
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, met)
+		})
+	}
+}
+
+func TestConditionalResource(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+	manager.AddFacter(StaticFacter{"os": "linux"})
+
+	linuxFile := &File{Path: "linux.txt", Content: FileContentLiteral("linux")}
+	windowsFile := &File{Path: "windows.txt", Content: FileContentLiteral("windows")}
+	dependent := &File{Path: "dependent.txt", DependsOn: Resources{windowsFile}}
+	resources := Resources{
+		When(FactEquals("os", "linux"), linuxFile),
+		When(FactEquals("os", "windows"), windowsFile),
+		dependent,
+	}
+
+	results, err := manager.Apply(resources)
+	t.Log(results)
+	require.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, ActionCreate, results[0].action)
+		assert.Equal(t, ActionSkip, results[1].action)
+		assert.Equal(t, reasonDisabled, results[1].reason)
+		assert.Equal(t, "{skip: [File::windows.txt], reason: condition not met}", results[1].String())
+		assert.Equal(t, ActionCreate, results[2].action)
+	}
+
+	_, err = os.Stat(filepath.Join(provider.Prefix, linuxFile.Path))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(provider.Prefix, windowsFile.Path))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// Wrapped resources are compared with the wrapped definition.
+	results, err = manager.Apply(resources)
+	require.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ActionSkip, results[0].action)
+	}
+}
+
+func TestConditionalResourceError(t *testing.T) {
+	failing := func(Scope) (bool, error) { return false, errors.New("cannot evaluate") }
+	resource := &dummyResource{absent: true}
+
+	manager := NewManager()
+	plan, err := manager.Plan(context.Background(), Resources{When(failing, resource)})
+	assert.Error(t, err)
+	if assert.Len(t, plan, 1) {
+		assert.Equal(t, ActionUnknown, plan[0].Action())
+		assert.ErrorContains(t, plan[0].Err(), "cannot evaluate")
+	}
+	assert.Zero(t, resource.created)
+}
+
+func TestConditionalModule(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+	manager.AddFacter(StaticFacter{"os": "linux"})
+
+	linuxModule := &Module{
+		Name: "linux",
+		// Conditions are evaluated in the scope where they are defined, not
+		// in the scope of the module.
+		Params: map[string]string{"os": "windows"},
+		Resources: Resources{
+			&File{Path: "linux.txt", Content: FileContentLiteral("linux")},
+			When(FactEquals("os", "windows"), &File{Path: "nested.txt"}),
+		},
+	}
+	windowsModule := &Module{
+		Name: "windows",
+		Resources: Resources{
+			&File{Path: "windows.txt", Content: FileContentLiteral("windows")},
+		},
+	}
+	windowsConfig := When(FactEquals("os", "windows"), &Directory{
+		Path:   "config",
+		Source: NewSourceFS(os.DirFS("testdata/directory/config")),
+	})
+	dependent := &File{Path: "dependent.txt", DependsOn: Resources{windowsConfig}}
+	resources := Resources{
+		When(FactEquals("os", "linux"), linuxModule),
+		When(FactEquals("os", "windows"), windowsModule),
+		windowsConfig,
+		dependent,
+	}
+
+	results, err := manager.Apply(resources)
+	t.Log(results)
+	require.NoError(t, err)
+
+	actions := make(map[string]string)
+	for _, result := range results {
+		actions[ResourceID(result.Resource())] = result.Action()
+	}
+	assert.Equal(t, ActionCreate, actions["[File::linux.txt]"])
+	assert.Equal(t, ActionCreate, actions["[File::nested.txt]"])
+	assert.Equal(t, ActionSkip, actions["[File::windows.txt]"])
+	assert.Equal(t, ActionSkip, actions["[File::config]"])
+	assert.Equal(t, ActionCreate, actions["[File::dependent.txt]"])
+
+	_, err = fsys.Stat("windows.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.Stat("config")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fsys.Stat("linux.txt")
+	assert.NoError(t, err)
+}
+
+func TestConditionalExpansionError(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+	manager.AddFacter(StaticFacter{"os": "linux"})
+
+	broken := func(path string) *Directory {
+		return &Directory{
+			Path:   path,
+			Source: NewSourceFS(os.DirFS("testdata/directory")),
+			Root:   "notfound",
+		}
+	}
+
+	// Resources that cannot be expanded are not a problem if they are not applied.
+	disabled := When(FactEquals("os", "windows"), broken("disabled"))
+	excluded := broken("excluded")
+	manager.SetFilter(func(resource Resource, module string) bool {
+		return !strings.Contains(ResourceID(resource), "excluded")
+	})
+	results, err := manager.Apply(Resources{disabled, excluded})
+	t.Log(results)
+	require.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, ActionSkip, results[0].Action())
+		assert.Equal(t, ActionSkip, results[1].Action())
+	}
+
+	enabled := When(FactEquals("os", "linux"), broken("enabled"))
+	results, err = manager.Apply(Resources{enabled})
+	assert.ErrorContains(t, err, "failed to expand")
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, ActionUnknown, results[0].Action())
+	}
+}
@@ -0,0 +1,302 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxDiffSize is the maximum size of the contents compared to obtain diffs.
+	maxDiffSize = 1 << 20
+
+	// maxDiffEdits is the maximum number of edits calculated for diffs, if more
+	// are needed, all the lines are reported as replaced.
+	maxDiffEdits = 1000
+
+	// diffContext is the number of lines of context included in diff hunks.
+	diffContext = 3
+)
+
+type diffsEnabledKey struct{}
+
+// withDiffs returns a context where resource states keep what they need to
+// report differences.
+func withDiffs(ctx context.Context) context.Context {
+	return context.WithValue(ctx, diffsEnabledKey{}, true)
+}
+
+// diffsEnabled returns true if differences are going to be reported for the
+// states obtained with the context.
+func diffsEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(diffsEnabledKey{}).(bool)
+	return enabled
+}
+
+// diffBuffer is a writer that keeps the content written to it, up to a maximum
+// size.
+type diffBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+// Write keeps the content written, till the maximum size is reached. It never
+// fails so it can be used in multiwriters.
+func (b *diffBuffer) Write(p []byte) (int, error) {
+	if b.truncated {
+		return len(p), nil
+	}
+	if b.buf.Len()+len(p) > maxDiffSize {
+		b.truncated = true
+		b.buf.Reset()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// isBinary returns true if the content looks like binary content.
+func isBinary(content []byte) bool {
+	const sniffLen = 8000
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// contentDiff returns the differences between two contents, as unified diff
+// when possible.
+func contentDiff(name string, current, expected *diffBuffer) string {
+	switch {
+	case current.truncated || expected.truncated:
+		return fmt.Sprintf("Content of %s differs, too large to show differences\n", name)
+	case isBinary(current.buf.Bytes()) || isBinary(expected.buf.Bytes()):
+		return fmt.Sprintf("Binary content of %s differs\n", name)
+	default:
+		return unifiedDiff("a/"+name, "b/"+name, current.buf.Bytes(), expected.buf.Bytes())
+	}
+}
+
+// diffOp is an operation in an edit script, it is a line with a kind of
+// operation: ' ' for equal lines, '-' for deleted lines and '+' for inserted lines.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the unified diff between two texts. It returns an empty
+// string if they are equal.
+func unifiedDiff(oldName, newName string, old, new []byte) string {
+	ops := diffLines(splitLines(old), splitLines(new))
+	hunks := formatHunks(ops)
+	if hunks == "" {
+		return ""
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n%s", oldName, newName, hunks)
+}
+
+// splitLines splits a text in lines, keeping the line terminators.
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(text), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edit script to transform a into b.
+func diffLines(a, b []string) []diffOp {
+	// Common prefix and suffix are kept out of the diff algorithm.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	middle, ok := myersDiff(middleA, middleB, maxDiffEdits)
+	if !ok {
+		middle = nil
+		for _, line := range middleA {
+			middle = append(middle, diffOp{'-', line})
+		}
+		for _, line := range middleB {
+			middle = append(middle, diffOp{'+', line})
+		}
+	}
+	ops = append(ops, middle...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff returns the shortest edit script to transform a into b, using the
+// Myers' diff algorithm. It returns false if more than maxEdits are needed.
+func myersDiff(a, b []string, maxEdits int) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	maxD := min(n+m, maxEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(trace, offset, a, b), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// myersBacktrack obtains the edit script from the trace of the Myers' algorithm.
+func myersBacktrack(trace [][]int, offset int, a, b []string) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// formatHunks formats an edit script as unified diff hunks.
+func formatHunks(ops []diffOp) string {
+	// Positions in the old and new texts before each operation.
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	next := 0
+	for next < len(ops) {
+		change := next
+		for change < len(ops) && ops[change].kind == ' ' {
+			change++
+		}
+		if change == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough.
+		end := change
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			equal := end
+			for equal < len(ops) && ops[equal].kind == ' ' {
+				equal++
+			}
+			if equal < len(ops) && equal-end <= 2*diffContext {
+				end = equal
+				continue
+			}
+			break
+		}
+
+		start := max(change-diffContext, next)
+		stop := min(end+diffContext, len(ops))
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[start], oldPos[stop]-oldPos[start]),
+			hunkRange(newPos[start], newPos[stop]-newPos[start]))
+		for _, op := range ops[start:stop] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		next = stop
+	}
+	return sb.String()
+}
+
+// hunkRange formats the range of lines of a hunk, start is zero-based.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		title    string
+		old, new string
+		expected string
+	}{
+		{
+			title: "equal",
+			old:   "a\nb\n",
+			new:   "a\nb\n",
+		},
+		{
+			title:    "from empty",
+			old:      "",
+			new:      "a\nb\n",
+			expected: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			title:    "to empty",
+			old:      "a\n",
+			new:      "",
+			expected: "--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			title:    "changed line",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:      "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			title:    "separated hunks",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:      "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			expected: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			title:    "no newline at end",
+			old:      "a\nb",
+			new:      "a\nb\n",
+			expected: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			diff := unifiedDiff("a", "b", []byte(c.old), []byte(c.new))
+			assert.Equal(t, c.expected, diff)
+		})
+	}
+}
+
+func TestContentDiff(t *testing.T) {
+	t.Run("binary", func(t *testing.T) {
+		var current, expected diffBuffer
+		current.Write([]byte("text"))
+		expected.Write([]byte{0, 1, 2})
+		assert.Equal(t, "Binary content of file.bin differs\n", contentDiff("file.bin", &current, &expected))
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		var current, expected diffBuffer
+		current.Write([]byte("text"))
+		expected.Write(bytes.Repeat([]byte("a"), maxDiffSize+1))
+		assert.True(t, expected.truncated)
+		assert.Equal(t, "Content of file.txt differs, too large to show differences\n", contentDiff("file.txt", &current, &expected))
+	})
+}
+
+func TestFileUpdateDiff(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.SetDiffs(true)
+
+	path := filepath.Join(provider.Prefix, "config.txt")
+	err := os.WriteFile(path, []byte("name: foo\nport: 80\n"), 0644)
+	require.NoError(t, err)
+
+	resource := File{
+		Path:    "/config.txt",
+		Mode:    FileMode(0600),
+		Content: FileContentLiteral("name: foo\nport: 8080\n"),
+	}
+
+	plan, err := manager.Plan(context.Background(), Resources{&resource})
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	assert.Equal(t, ActionUpdate, plan[0].Action())
+
+	expected := strings.Join([]string{
+		"old mode 0644",
+		"new mode 0600",
+		"--- a/config.txt",
+		"+++ b/config.txt",
+		"@@ -1,2 +1,2 @@",
+		" name: foo",
+		"-port: 80",
+		"+port: 8080",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, plan[0].Diff())
+
+	result, err := manager.ApplyPlan(context.Background(), plan)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, ActionUpdate, result[0].action)
+	assert.Equal(t, expected, result[0].Diff())
+
+	d, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "name: foo\nport: 8080\n", string(d))
+}
+
+func TestFileUpdateDiffDisabled(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	err := os.WriteFile(filepath.Join(provider.Prefix, "config.txt"), []byte("old\n"), 0644)
+	require.NoError(t, err)
+
+	resource := File{
+		Path:    "/config.txt",
+		Content: FileContentLiteral("new\n"),
+	}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, ActionUpdate, result[0].action)
+	assert.Empty(t, result[0].Diff())
+}
+
+func TestFileStateKeepsContentsOnlyForDiffs(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	err := os.WriteFile(filepath.Join(provider.Prefix, "config.txt"), []byte("old\n"), 0644)
+	require.NoError(t, err)
+	resource := File{
+		Path:    "/config.txt",
+		Content: FileContentLiteral("new\n"),
+	}
+
+	for _, enabled := range []bool{false, true} {
+		ctx := context.Background()
+		if enabled {
+			ctx = withDiffs(ctx)
+		}
+		current, err := resource.Get(ctx, manager)
+		require.NoError(t, err)
+		needsUpdate, err := current.NeedsUpdate(ctx, &resource)
+		require.NoError(t, err)
+		assert.True(t, needsUpdate)
+
+		state := current.(*FileState)
+		assert.Equal(t, enabled, state.currentContent != nil, "current content kept")
+		assert.Equal(t, enabled, state.expectedContent != nil, "expected content kept")
+	}
+}
@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Directory is a resource that manages a directory with the content of a
+// directory in a source file system. It is managed as a collection of files,
+// the result of each file created, updated or removed is reported. Files are
+// created with the default modes, only the executable bits of source files are
+// mirrored, so scripts can be executed.
+type Directory struct {
+	// Provider is the name of the file provider to use, defaults to "file".
+	Provider string
+	// Path is the path of the managed directory.
+	Path string
+	// Source is the source file system where the content is obtained from.
+	Source *SourceFS
+	// Root is the path of the directory in the source file system whose content
+	// is copied to the managed directory. Defaults to the root of the source.
+	Root string
+	// TemplateSuffix is the suffix of the files in the source that are rendered
+	// as templates. The suffix is removed from the name of the managed files.
+	// If not set, no file is rendered as template.
+	TemplateSuffix string
+	// Purge is set to true to remove files in the managed directory that are not
+	// in the source.
+	Purge bool
+	// CreateParent is set to true if parent path should be created too.
+	CreateParent bool
+	// Force forces destructive operations, such as removing a file to replace it
+	// with a directory, or the other way around.
+	Force bool
+	// DependsOn is the list of resources that need to be applied before this directory.
+	DependsOn Resources
+}
+
+func (d *Directory) String() string {
+	return fmt.Sprintf("[Directory:%s:%s]", d.Provider, d.Path)
+}
+
+// Expand returns the files that need to be managed to mirror the source in the
+// managed directory.
+func (d *Directory) Expand(ctx context.Context, scope Scope) (Resources, error) {
+	if d.Source == nil {
+		return nil, errors.New("source is required")
+	}
+	root := d.Root
+	if root == "" {
+		root = "."
+	}
+
+	dir := &File{
+		Provider:     d.Provider,
+		Path:         d.Path,
+		Directory:    true,
+		CreateParent: d.CreateParent,
+		Force:        d.Force,
+		DependsOn:    d.DependsOn,
+	}
+	resources := Resources{dir}
+
+	// Files are indexed by their relative path in the managed directory.
+	files := map[string]*File{".": dir}
+	err := fs.WalkDir(d.Source, root, func(sourcePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if sourcePath == root {
+			return nil
+		}
+		rel := sourcePath
+		if root != "." {
+			rel = strings.TrimPrefix(sourcePath, root+"/")
+		}
+
+		file := &File{
+			Provider: d.Provider,
+			Force:    d.Force,
+		}
+		switch {
+		case entry.IsDir():
+			file.Directory = true
+		case d.TemplateSuffix != "" && strings.HasSuffix(rel, d.TemplateSuffix):
+			rel = strings.TrimSuffix(rel, d.TemplateSuffix)
+			file.Content = d.Source.Template(sourcePath)
+		default:
+			file.Content = d.Source.File(sourcePath)
+		}
+		if !entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if exec := info.Mode().Perm() & 0111; exec != 0 {
+				mode := fs.FileMode(0644) | exec
+				file.Mode = &mode
+			}
+		}
+		file.Path = filepath.Join(d.Path, filepath.FromSlash(rel))
+		file.DependsOn = Resources{files[path.Dir(rel)]}
+
+		files[rel] = file
+		resources = append(resources, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+
+	if d.Purge {
+		provider := fileProvider(scope, d.Provider)
+		purged, err := d.purgedFiles(provider, ".", files)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files to purge: %w", err)
+		}
+		resources = append(resources, purged...)
+	}
+
+	return resources, nil
+}
+
+// purgedFiles returns the resources to remove the files in the managed directory
+// that are not in the source. Backups kept by the provider are not purged.
+func (d *Directory) purgedFiles(provider *FileProvider, dir string, files map[string]*File) (Resources, error) {
+	entries, err := provider.fs().ReadDir(filepath.Join(d.Path, filepath.FromSlash(dir)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var purged Resources
+	for _, entry := range entries {
+		rel := path.Join(dir, entry.Name())
+		file, found := files[rel]
+		_, isBackup := backupOriginal(entry.Name())
+		switch {
+		case !found && isBackup && provider.Backup != nil:
+		case !found:
+			purged = append(purged, &File{
+				Provider:  d.Provider,
+				Path:      filepath.Join(d.Path, filepath.FromSlash(rel)),
+				Absent:    true,
+				Force:     true,
+				DependsOn: Resources{files[dir]},
+			})
+		case file.Directory && entry.IsDir():
+			p, err := d.purgedFiles(provider, rel, files)
+			if err != nil {
+				return nil, err
+			}
+			purged = append(purged, p...)
+		}
+	}
+	return purged, nil
+}
+
+// Get returns an error, directories are expanded by the manager, they cannot be
+// managed as single resources.
+func (d *Directory) Get(context.Context, Scope) (ResourceState, error) {
+	return nil, d.errNotExpanded()
+}
+
+// Create returns an error, directories are expanded by the manager, they cannot be
+// managed as single resources.
+func (d *Directory) Create(context.Context, Scope) error {
+	return d.errNotExpanded()
+}
+
+// Update returns an error, directories are expanded by the manager, they cannot be
+// managed as single resources.
+func (d *Directory) Update(context.Context, Scope) error {
+	return d.errNotExpanded()
+}
+
+func (d *Directory) errNotExpanded() error {
+	return fmt.Errorf("directory %s must be directly included in the resources applied by a manager", d.Path)
+}
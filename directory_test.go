@@ -0,0 +1,256 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+	manager.AddFacter(StaticFacter{"name": "world"})
+
+	resource := Directory{
+		Path:           "config",
+		Source:         NewSourceFS(os.DirFS("testdata/directory")),
+		Root:           "config",
+		TemplateSuffix: ".tmpl",
+	}
+	resources := Resources{&resource}
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 5) {
+		for _, r := range result {
+			assert.Equal(t, ActionCreate, r.action)
+		}
+	}
+
+	expected := map[string]string{
+		"config/app.yml":          "name: sample\n",
+		"config/sub/settings.txt": "Some settings.\n",
+		"config/sub/hello.txt":    "Hello world!\n",
+	}
+	for path, content := range expected {
+		d, err := os.ReadFile(filepath.Join(provider.Prefix, path))
+		if assert.NoError(t, err) {
+			assert.Equal(t, content, string(d))
+		}
+	}
+
+	// On second apply, it should do nothing.
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	// Changes in files are reported as updates.
+	err = os.WriteFile(filepath.Join(provider.Prefix, "config/app.yml"), []byte("name: other\n"), 0644)
+	require.NoError(t, err)
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+		assert.Equal(t, "{update: [File::config/app.yml]}", result[0].String())
+	}
+}
+
+func TestDirectoryPurge(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := Directory{
+		Path:   "config",
+		Source: NewSourceFS(os.DirFS("testdata/directory/config")),
+	}
+	resources := Resources{&resource}
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+
+	extra := []string{"config/extra.txt", "config/sub/extra.txt", "config/extradir/extra.txt"}
+	for _, path := range extra {
+		path = filepath.Join(provider.Prefix, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("extra"), 0644))
+	}
+
+	// Without purge, extra files are kept.
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	resource.Purge = true
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 3) {
+		for _, r := range result {
+			assert.Equal(t, ActionUpdate, r.action)
+		}
+	}
+	for _, path := range append(extra, "config/extradir") {
+		_, err := os.Stat(filepath.Join(provider.Prefix, path))
+		assert.True(t, errors.Is(err, os.ErrNotExist), path)
+	}
+	_, err = os.Stat(filepath.Join(provider.Prefix, "config/sub/hello.txt.tmpl"))
+	assert.NoError(t, err)
+
+	// On second apply, it should do nothing.
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestDirectoryDependency(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	directory := &Directory{
+		Path:   "config",
+		Source: NewSourceFS(os.DirFS("testdata/directory/config")),
+	}
+	dependent := &File{
+		Path:      "config/sub/other.txt",
+		DependsOn: Resources{directory},
+	}
+
+	result, err := manager.Apply(Resources{dependent, directory})
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 6) {
+		assert.Equal(t, dependent, result[5].resource)
+	}
+}
+
+func TestDirectorySourceNotFound(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	directory := &Directory{
+		Path:   "config",
+		Source: NewSourceFS(os.DirFS("testdata/directory")),
+		Root:   "notfound",
+	}
+	dependent := &File{
+		Path:      "other.txt",
+		DependsOn: Resources{directory},
+	}
+
+	result, err := manager.Apply(Resources{directory, dependent})
+	t.Log(result)
+	assert.Error(t, err)
+	if assert.Len(t, result, 2) {
+		assert.Equal(t, ActionUnknown, result[0].action)
+		assert.Equal(t, directory, result[0].resource)
+		assert.Equal(t, ActionSkip, result[1].action)
+	}
+}
+
+func TestDirectoryForceExisting(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+	manager.AddFacter(StaticFacter{"name": "world"})
+
+	// Existing directories with other modes are updated, and files are replaced
+	// by directories.
+	require.NoError(t, os.Mkdir(filepath.Join(provider.Prefix, "config"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(provider.Prefix, "config", "sub"), []byte("file"), 0644))
+
+	resource := Directory{
+		Path:           "config",
+		Source:         NewSourceFS(os.DirFS("testdata/directory")),
+		Root:           "config",
+		TemplateSuffix: ".tmpl",
+		Force:          true,
+	}
+	result, err := manager.Apply(Resources{&resource})
+	t.Log(result)
+	require.NoError(t, err)
+	assert.Len(t, result, 5)
+
+	info, err := os.Stat(filepath.Join(provider.Prefix, "config"))
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+	assert.DirExists(t, filepath.Join(provider.Prefix, "config", "sub"))
+	assert.FileExists(t, filepath.Join(provider.Prefix, "config", "sub", "hello.txt"))
+
+	result, err = manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestDirectoryExecutableFiles(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	source := fstest.MapFS{
+		"bin/run.sh":     {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+		"bin/owner.sh":   {Data: []byte("#!/bin/sh\n"), Mode: 0700},
+		"bin/readme.txt": {Data: []byte("Scripts.\n"), Mode: 0600},
+	}
+	resource := Directory{
+		Path:   "scripts",
+		Source: NewSourceFS(source),
+		Root:   "bin",
+	}
+	result, err := manager.Apply(Resources{&resource})
+	t.Log(result)
+	require.NoError(t, err)
+
+	for name, mode := range map[string]fs.FileMode{"run.sh": 0755, "owner.sh": 0744, "readme.txt": 0644} {
+		info, err := fsys.Stat(filepath.Join("scripts", name))
+		if assert.NoError(t, err) {
+			assert.Equal(t, mode, info.Mode().Perm(), name)
+		}
+	}
+
+	result, err = manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// nodePlanner obtains the action to apply for a node.
+type nodePlanner func(*resourceNode) PlannedAction
+
+// execute applies the actions obtained for the given nodes, that must be sorted
+// so dependencies are before the nodes depending on them. Resources whose
+// dependencies failed are skipped. Notified handlers are run at the end, unless
+// changes are reverted in transactional mode.
+// Results are returned in the same order as the nodes, even if they are
+// applied concurrently.
+func (m *Manager) execute(ctx context.Context, nodes []*resourceNode, plan nodePlanner) (ApplyResults, error) {
+	var executed []*ApplyResult
+	var interrupted error
+	var reverted bool
+	switch {
+	case m.transactional:
+		executed, reverted, interrupted = m.executeTransaction(ctx, nodes, plan)
+	case m.parallelism > 1:
+		executed, interrupted = m.executeParallel(ctx, nodes, plan)
+	default:
+		executed, interrupted = m.executeSequential(ctx, nodes, plan)
+	}
+	if !reverted {
+		executed = append(executed, m.runHandlers(ctx, nodes, executed)...)
+	}
+
+	var results ApplyResults
+	var errors []error
+	for _, result := range executed {
+		if result == nil {
+			continue
+		}
+		if result.err != nil {
+			errors = append(errors, result.err)
+		}
+		results = append(results, *result)
+	}
+	if interrupted != nil {
+		errors = append(errors, fmt.Errorf("apply interrupted: %w", interrupted))
+	}
+	return results, newApplyError(errors)
+}
+
+// executeSequential applies the nodes one after the other. It stops when the
+// context is done, returning the context error.
+func (m *Manager) executeSequential(ctx context.Context, nodes []*resourceNode, plan nodePlanner) ([]*ApplyResult, error) {
+	results := make([]*ApplyResult, len(nodes))
+	failed := make(map[*resourceNode]bool)
+	isFailed := func(node *resourceNode) bool { return failed[node] }
+	for i, node := range nodes {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		var nodeFailed bool
+		results[i], nodeFailed = m.executeNode(ctx, node, isFailed, plan)
+		failed[node] = nodeFailed
+	}
+	return results, nil
+}
+
+// executeParallel applies the nodes concurrently, up to the configured parallelism.
+// Nodes are only applied after all their dependencies have been applied. No new
+// node is applied after the context is done, in that case the context error is
+// returned.
+func (m *Manager) executeParallel(ctx context.Context, nodes []*resourceNode, plan nodePlanner) ([]*ApplyResult, error) {
+	results := make([]*ApplyResult, len(nodes))
+	failed := make([]bool, len(nodes))
+	done := make([]chan struct{}, len(nodes))
+	index := make(map[*resourceNode]int, len(nodes))
+	for i, node := range nodes {
+		done[i] = make(chan struct{})
+		index[node] = i
+	}
+	isFailed := func(node *resourceNode) bool {
+		i, found := index[node]
+		return found && failed[i]
+	}
+
+	var interruptedOnce sync.Once
+	var interrupted error
+	interrupt := func(err error) {
+		interruptedOnce.Do(func() { interrupted = err })
+	}
+
+	workers := make(chan struct{}, m.parallelism)
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, dependency := range node.dependencies {
+				if j, found := index[dependency]; found {
+					<-done[j]
+				}
+			}
+
+			select {
+			case workers <- struct{}{}:
+				defer func() { <-workers }()
+			case <-ctx.Done():
+			}
+			if err := ctx.Err(); err != nil {
+				failed[i] = true
+				interrupt(err)
+				return
+			}
+
+			results[i], failed[i] = m.executeNode(ctx, node, isFailed, plan)
+		}()
+	}
+	wg.Wait()
+
+	return results, interrupted
+}
+
+// executeNode applies the action planned for a node, or skips it if any of its
+// dependencies failed. It returns the result, and true if the node failed or was
+// skipped because of a failed dependency.
+func (m *Manager) executeNode(ctx context.Context, node *resourceNode, failed func(*resourceNode) bool, plan nodePlanner) (*ApplyResult, bool) {
+	if dependency := failedDependency(node, failed); dependency != nil {
+		return &ApplyResult{
+			action:   ActionSkip,
+			resource: node.resource,
+			module:   node.module,
+			reason:   fmt.Sprintf("dependency %s failed", dependency.resource),
+			started:  time.Now(),
+		}, true
+	}
+
+	result := m.applyPlannedAction(ctx, plan(node))
+	return result, result != nil && result.err != nil
+}
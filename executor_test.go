@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerParallel(t *testing.T) {
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var counter concurrencyCounter
+		var resources Resources
+		for range 20 {
+			resources = append(resources, &concurrentResource{counter: &counter})
+		}
+
+		m := NewManager()
+		m.SetParallelism(4)
+		results, err := m.Apply(resources)
+		require.NoError(t, err)
+		if assert.Len(t, results, len(resources)) {
+			for i, result := range results {
+				assert.Equal(t, resources[i], result.resource, "results should keep order")
+			}
+		}
+		assert.LessOrEqual(t, counter.max.Load(), int64(4))
+		assert.Greater(t, counter.max.Load(), int64(1))
+	})
+	t.Run("dependencies are respected", func(t *testing.T) {
+		var counter concurrencyCounter
+		first := &concurrentResource{counter: &counter}
+		second := &concurrentResource{counter: &counter, dependencies: Resources{first}}
+		third := &concurrentResource{counter: &counter, dependencies: Resources{second}}
+
+		m := NewManager()
+		m.SetParallelism(4)
+		results, err := m.Apply(Resources{third, second, first})
+		require.NoError(t, err)
+		if assert.Len(t, results, 3) {
+			assert.Equal(t, first, results[0].resource)
+			assert.Equal(t, second, results[1].resource)
+			assert.Equal(t, third, results[2].resource)
+		}
+		assert.True(t, first.finished.Before(second.started))
+		assert.True(t, second.finished.Before(third.started))
+	})
+	t.Run("dependents skipped on failure", func(t *testing.T) {
+		var counter concurrencyCounter
+		failing := &concurrentResource{counter: &counter, err: errors.New("failed")}
+		dependent := &concurrentResource{counter: &counter, dependencies: Resources{failing}}
+		independent := &concurrentResource{counter: &counter}
+
+		m := NewManager()
+		m.SetParallelism(4)
+		results, err := m.Apply(Resources{failing, dependent, independent})
+		assert.Error(t, err)
+		if assert.Len(t, results, 3) {
+			assert.Error(t, results[0].Err())
+			assert.Equal(t, ActionSkip, results[1].action)
+			assert.Equal(t, ActionCreate, results[2].action)
+		}
+		assert.True(t, dependent.started.IsZero())
+	})
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var counter concurrencyCounter
+		first := &concurrentResource{counter: &counter, onCreate: cancel}
+		second := &concurrentResource{counter: &counter, dependencies: Resources{first}}
+
+		m := NewManager()
+		m.SetParallelism(4)
+		results, err := m.ApplyCtx(ctx, Resources{first, second})
+		if assert.Error(t, err) {
+			assert.True(t, errors.Is(err, context.Canceled))
+		}
+		assert.Len(t, results, 1)
+		assert.True(t, second.started.IsZero())
+	})
+}
+
+type concurrencyCounter struct {
+	current atomic.Int64
+	max     atomic.Int64
+}
+
+func (c *concurrencyCounter) start() {
+	current := c.current.Add(1)
+	for {
+		max := c.max.Load()
+		if current <= max || c.max.CompareAndSwap(max, current) {
+			return
+		}
+	}
+}
+
+func (c *concurrencyCounter) finish() {
+	c.current.Add(-1)
+}
+
+type concurrentResource struct {
+	counter      *concurrencyCounter
+	dependencies Resources
+	err          error
+	onCreate     func()
+
+	mutex    sync.Mutex
+	started  time.Time
+	finished time.Time
+}
+
+// String identifies the resource without reading its state, that is modified
+// concurrently.
+func (r *concurrentResource) String() string {
+	return fmt.Sprintf("[concurrentResource:%p]", r)
+}
+
+func (r *concurrentResource) Get(context.Context, Scope) (ResourceState, error) {
+	return &dummyResourceState{absent: true}, nil
+}
+
+func (r *concurrentResource) Create(context.Context, Scope) error {
+	r.counter.start()
+	defer r.counter.finish()
+
+	r.mutex.Lock()
+	r.started = time.Now()
+	r.mutex.Unlock()
+
+	if r.onCreate != nil {
+		r.onCreate()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	r.mutex.Lock()
+	r.finished = time.Now()
+	r.mutex.Unlock()
+	return r.err
+}
+
+func (r *concurrentResource) Update(context.Context, Scope) error { return nil }
+
+func (r *concurrentResource) Dependencies() Resources { return r.dependencies }
@@ -17,10 +17,107 @@
 
 package resource
 
-import "os"
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
 
 const defaultEnvFacterPrefix = "FACT"
 
+// factValue returns the value of a fact obtained from a facter, keeping its type
+// if the facter is typed.
+func factValue(facter Facter, name string) (any, bool) {
+	if typed, ok := facter.(TypedFacter); ok {
+		return typed.FactValue(name)
+	}
+	return facter.Fact(name)
+}
+
+// factBool returns the value of a fact in the scope as a boolean.
+func factBool(scope Scope, name string) (bool, error) {
+	value, found := scope.FactValue(name)
+	if !found {
+		return false, errFactNotFound(scope, name)
+	}
+	switch value := value.(type) {
+	case bool:
+		return value, nil
+	case string:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("fact %q is not a boolean: %q", name, value)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("fact %q is not a boolean: %v", name, value)
+	}
+}
+
+// factInt returns the value of a fact in the scope as an integer.
+func factInt(scope Scope, name string) (int, error) {
+	value, found := scope.FactValue(name)
+	if !found {
+		return 0, errFactNotFound(scope, name)
+	}
+	switch value := value.(type) {
+	case int:
+		return value, nil
+	case int64:
+		return int(value), nil
+	case uint64:
+		return int(value), nil
+	case float64:
+		if value == math.Trunc(value) {
+			return int(value), nil
+		}
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("fact %q is not an integer: %v", name, value)
+}
+
+// factList returns the value of a fact in the scope as a list of strings.
+func factList(scope Scope, name string) ([]string, error) {
+	value, found := scope.FactValue(name)
+	if !found {
+		return nil, errFactNotFound(scope, name)
+	}
+	switch value := value.(type) {
+	case []string:
+		return value, nil
+	case []any:
+		list := make([]string, len(value))
+		for i, v := range value {
+			list[i] = fmt.Sprint(v)
+		}
+		return list, nil
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return []string{}, nil
+		}
+		list := strings.Split(value, ",")
+		for i := range list {
+			list[i] = strings.TrimSpace(list[i])
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("fact %q is not a list: %v", name, value)
+	}
+}
+
 // EnvFacter is a facter that gets facts from environment variables.
 // Facts can be defined in environment variables starting with the "FACT"
 // prefix.
@@ -35,10 +132,203 @@ type EnvFacter struct {
 // Fact returns the value of a fact obtained from the environment if it
 // exists. If not, it returns an empty string and false.
 func (f *EnvFacter) Fact(name string) (string, bool) {
-	prefix := f.Prefix
-	if prefix == "" {
-		prefix = defaultEnvFacterPrefix
-	}
-	envName := prefix + "_" + name
+	envName := f.prefix() + "_" + name
 	return os.LookupEnv(envName)
 }
+
+// FactNames returns the names of the facts defined in the environment.
+func (f *EnvFacter) FactNames() []string {
+	prefix := f.prefix() + "_"
+	var names []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			names = append(names, strings.TrimPrefix(name, prefix))
+		}
+	}
+	return names
+}
+
+func (f *EnvFacter) String() string {
+	return fmt.Sprintf("[EnvFacter:%s]", f.prefix())
+}
+
+func (f *EnvFacter) prefix() string {
+	if f.Prefix == "" {
+		return defaultEnvFacterPrefix
+	}
+	return f.Prefix
+}
+
+const (
+	defaultOSReleasePath         = "/etc/os-release"
+	defaultOSReleaseFallbackPath = "/usr/lib/os-release"
+	defaultProcPath              = "/proc"
+)
+
+// SystemFacter is a facter that gets facts about the system where resources are
+// applied. Available facts are:
+//   - os: operating system, as in runtime.GOOS.
+//   - arch: architecture, as in runtime.GOARCH.
+//   - hostname: name of the host.
+//   - user: name of the current user.
+//   - home: home directory of the current user.
+//   - cpus: number of logical CPUs.
+//   - os_id, os_version and os_name: distribution identifier, version and name,
+//     as found in os-release files.
+//   - kernel_version: version of the kernel, as found in the proc filesystem.
+//   - memory_total: total memory in bytes, as found in the proc filesystem.
+//
+// Facts that cannot be obtained in the system are not found. Facts are
+// collected the first time they are queried.
+type SystemFacter struct {
+	// OSReleasePath is the path to the os-release file. If not set,
+	// "/etc/os-release" is used, or "/usr/lib/os-release" if the former
+	// doesn't exist.
+	OSReleasePath string
+
+	// ProcPath is the path where the proc filesystem is mounted. If not
+	// set, "/proc" is used.
+	ProcPath string
+
+	once  sync.Once
+	facts map[string]string
+}
+
+// Fact returns the value of a fact about the system if it is known. If not,
+// it returns an empty string and false.
+func (f *SystemFacter) Fact(name string) (string, bool) {
+	f.once.Do(f.collect)
+	v, found := f.facts[name]
+	return v, found
+}
+
+// FactNames returns the names of the facts known in the system.
+func (f *SystemFacter) FactNames() []string {
+	f.once.Do(f.collect)
+	return slices.Collect(maps.Keys(f.facts))
+}
+
+func (f *SystemFacter) String() string {
+	return "[SystemFacter]"
+}
+
+func (f *SystemFacter) collect() {
+	f.facts = map[string]string{
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+		"cpus": strconv.Itoa(runtime.NumCPU()),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		f.facts["hostname"] = hostname
+	}
+	if current, err := user.Current(); err == nil {
+		f.facts["user"] = current.Username
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		f.facts["home"] = home
+	}
+
+	osRelease := readOSRelease(f.osReleasePaths())
+	for fact, key := range map[string]string{
+		"os_id":      "ID",
+		"os_version": "VERSION_ID",
+		"os_name":    "PRETTY_NAME",
+	} {
+		if v, found := osRelease[key]; found {
+			f.facts[fact] = v
+		}
+	}
+	if _, found := f.facts["os_name"]; !found && osRelease["NAME"] != "" {
+		f.facts["os_name"] = osRelease["NAME"]
+	}
+
+	procPath := f.ProcPath
+	if procPath == "" {
+		procPath = defaultProcPath
+	}
+	if d, err := os.ReadFile(filepath.Join(procPath, "sys", "kernel", "osrelease")); err == nil {
+		f.facts["kernel_version"] = strings.TrimSpace(string(d))
+	}
+	if memory, found := readMemTotal(filepath.Join(procPath, "meminfo")); found {
+		f.facts["memory_total"] = strconv.FormatUint(memory, 10)
+	}
+}
+
+func (f *SystemFacter) osReleasePaths() []string {
+	if f.OSReleasePath != "" {
+		return []string{f.OSReleasePath}
+	}
+	return []string{defaultOSReleasePath, defaultOSReleaseFallbackPath}
+}
+
+// readOSRelease reads the variables defined in the first os-release file found
+// in the given paths.
+func readOSRelease(paths []string) map[string]string {
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		vars := make(map[string]string)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, found := strings.Cut(line, "=")
+			if !found {
+				continue
+			}
+			vars[key] = unquoteValue(value)
+		}
+		return vars
+	}
+	return nil
+}
+
+// unquoteValue removes the quotes of a value in an os-release or dotenv file.
+func unquoteValue(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch value[0] {
+	case '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return strings.Trim(value, `"`)
+	case '\'':
+		return strings.Trim(value, "'")
+	}
+	return value
+}
+
+// readMemTotal reads the total memory in bytes from a meminfo file.
+func readMemTotal(path string) (uint64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		if len(fields) > 2 && strings.EqualFold(fields[2], "kB") {
+			value *= 1024
+		}
+		return value, true
+	}
+	return 0, false
+}
@@ -19,9 +19,13 @@ package resource
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEnvFacter(t *testing.T) {
@@ -44,3 +48,178 @@ func TestEnvFacter(t *testing.T) {
 	assert.Equal(t, expectedValue, value)
 	assert.True(t, found)
 }
+
+func TestSystemFacter(t *testing.T) {
+	dir := t.TempDir()
+	osRelease := filepath.Join(dir, "os-release")
+	err := os.WriteFile(osRelease, []byte(`# Some comment
+NAME="Ubuntu"
+VERSION_ID="24.04"
+ID=ubuntu
+PRETTY_NAME="Ubuntu 24.04 LTS"
+`), 0644)
+	require.NoError(t, err)
+
+	proc := filepath.Join(dir, "proc")
+	require.NoError(t, os.MkdirAll(filepath.Join(proc, "sys", "kernel"), 0755))
+	err = os.WriteFile(filepath.Join(proc, "sys", "kernel", "osrelease"), []byte("6.8.0-generic\n"), 0644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(proc, "meminfo"), []byte("MemTotal:       16318412 kB\nMemFree:         1234 kB\n"), 0644)
+	require.NoError(t, err)
+
+	facter := &SystemFacter{
+		OSReleasePath: osRelease,
+		ProcPath:      proc,
+	}
+	manager := NewManager()
+	manager.AddFacter(facter)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"cpus":           strconv.Itoa(runtime.NumCPU()),
+		"hostname":       hostname,
+		"os_id":          "ubuntu",
+		"os_version":     "24.04",
+		"os_name":        "Ubuntu 24.04 LTS",
+		"kernel_version": "6.8.0-generic",
+		"memory_total":   "16710053888",
+	}
+	for name, value := range expected {
+		v, found := manager.Fact(name)
+		if assert.True(t, found, name) {
+			assert.Equal(t, value, v, name)
+		}
+	}
+
+	_, found := manager.Fact("user")
+	assert.True(t, found)
+	_, found = manager.Fact("home")
+	assert.True(t, found)
+	_, found = manager.Fact("unknown")
+	assert.False(t, found)
+}
+
+func TestSystemFacterMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	facter := &SystemFacter{
+		OSReleasePath: filepath.Join(dir, "os-release"),
+		ProcPath:      filepath.Join(dir, "proc"),
+	}
+
+	for _, name := range []string{"os_id", "os_version", "os_name", "kernel_version", "memory_total"} {
+		_, found := facter.Fact(name)
+		assert.False(t, found, name)
+	}
+
+	v, found := facter.Fact("os")
+	assert.True(t, found)
+	assert.Equal(t, runtime.GOOS, v)
+}
+
+func TestUnquoteValue(t *testing.T) {
+	assert.Equal(t, "ubuntu", unquoteValue("ubuntu"))
+	assert.Equal(t, "Ubuntu 24.04", unquoteValue(`"Ubuntu 24.04"`))
+	assert.Equal(t, "Ubuntu 24.04", unquoteValue(`'Ubuntu 24.04'`))
+	assert.Equal(t, `Some "quoted" name`, unquoteValue(`"Some \"quoted\" name"`))
+}
+
+func TestTypedFacts(t *testing.T) {
+	manager := NewManager()
+	manager.AddFacter(StaticFacter{
+		"enabled": "true",
+		"count":   "3",
+		"hosts":   "a, b,c",
+		"empty":   "",
+		"name":    "foo",
+	})
+	manager.AddFacter(StaticValuesFacter{
+		"typed_enabled": true,
+		"typed_count":   int64(5),
+		"typed_float":   2.0,
+		"typed_hosts":   []any{"x", 1},
+		"typed_map":     map[string]any{"a": 1},
+	})
+
+	value, found := manager.FactValue("typed_count")
+	assert.True(t, found)
+	assert.Equal(t, int64(5), value)
+	value, found = manager.FactValue("count")
+	assert.True(t, found)
+	assert.Equal(t, "3", value)
+	s, found := manager.Fact("typed_count")
+	assert.True(t, found)
+	assert.Equal(t, "5", s)
+	_, found = manager.FactValue("notfound")
+	assert.False(t, found)
+
+	for _, name := range []string{"enabled", "typed_enabled"} {
+		b, err := manager.FactBool(name)
+		assert.NoError(t, err)
+		assert.True(t, b)
+	}
+	_, err := manager.FactBool("name")
+	assert.Error(t, err)
+	_, err = manager.FactBool("notfound")
+	assert.ErrorContains(t, err, `fact "notfound" not found`)
+
+	i, err := manager.FactInt("count")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, i)
+	i, err = manager.FactInt("typed_count")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, i)
+	i, err = manager.FactInt("typed_float")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, i)
+	_, err = manager.FactInt("name")
+	assert.Error(t, err)
+	_, err = manager.FactInt("typed_map")
+	assert.Error(t, err)
+
+	l, err := manager.FactList("hosts")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, l)
+	l, err = manager.FactList("typed_hosts")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x", "1"}, l)
+	l, err = manager.FactList("empty")
+	assert.NoError(t, err)
+	assert.Empty(t, l)
+	_, err = manager.FactList("typed_enabled")
+	assert.Error(t, err)
+}
+
+func TestManagerFacts(t *testing.T) {
+	t.Setenv("TESTFACTS_env", "fromenv")
+
+	manager := NewManager()
+	manager.AddFacter(StaticFacter{"env": "static", "name": "foo"})
+	manager.AddFacter(&EnvFacter{Prefix: "TESTFACTS"})
+	manager.AddFacter(StaticValuesFacter{"port": 80, "env": "typed"})
+	manager.AddFacter(unlistedFacter{})
+
+	facts := manager.Facts()
+	expected := []FactInfo{
+		{
+			Name:   "env",
+			Value:  "typed",
+			Source: "resource.StaticValuesFacter",
+			Shadowed: []ShadowedFact{
+				{Value: "fromenv", Source: "[EnvFacter:TESTFACTS]"},
+				{Value: "static", Source: "resource.StaticFacter"},
+			},
+		},
+		{Name: "name", Value: "foo", Source: "resource.StaticFacter"},
+		{Name: "port", Value: 80, Source: "resource.StaticValuesFacter"},
+	}
+	assert.Equal(t, expected, facts)
+}
+
+// unlistedFacter is a facter that cannot list its facts.
+type unlistedFacter struct{}
+
+func (unlistedFacter) Fact(name string) (string, bool) { return "unlisted", true }
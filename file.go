@@ -20,14 +20,15 @@ package resource
 import (
 	"bytes"
 	"context"
-	"crypto/md5"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 const (
@@ -35,47 +36,185 @@ const (
 )
 
 // FileProvider is a provider of files. It can be configured with the prefix
-// path where files should be managed.
+// path where files should be managed. Files are confined to the prefix, paths
+// out of it, also when reached through links, fail with ErrPathEscapes.
 type FileProvider struct {
 	Prefix string
+
+	// FS is the file system where files are managed, paths of files are relative
+	// to its root. If not set, the file system of the operating system is used,
+	// with the root in Prefix. Prefix is ignored when FS is set.
+	FS WritableFS
+
+	// Owners is used to resolve the names of owners and groups of files. If not
+	// set, the users and groups database of the system is used.
+	Owners OwnerLookup
+
+	// Backup configures backups of files whose content is replaced, or that are
+	// removed. Backups are disabled if not set.
+	Backup *FileBackup
+}
+
+// fs returns the file system where files are managed. When a prefix is set,
+// files are confined to it.
+func (p *FileProvider) fs() WritableFS {
+	switch {
+	case p.FS != nil:
+		return p.FS
+	case p.Prefix != "":
+		return rootFS{prefix: p.Prefix}
+	default:
+		return osFS{}
+	}
+}
+
+// checkPath returns an error if the path is out of the prefix of the provider.
+// Paths reaching out of the prefix through links are only detected when they
+// are accessed.
+func (p *FileProvider) checkPath(name string) error {
+	if p.FS != nil || p.Prefix == "" {
+		return nil
+	}
+	_, err := rootName(p.Prefix, "access", name)
+	return err
+}
+
+// path returns the complete path of a file managed by the provider.
+func (p *FileProvider) path(name string) string {
+	if p.FS != nil {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(p.Prefix, name)
+}
+
+func (p *FileProvider) owners() OwnerLookup {
+	if p.Owners == nil {
+		return systemOwnerLookup{}
+	}
+	return p.Owners
 }
 
 // File is a resource that manages a file.
 type File struct {
 	// Provider is the name of the provider to use, defaults to "file".
-	Provider string
+	Provider string `yaml:"provider"`
 	// Path is the path of the file.
-	Path string
+	Path string `yaml:"path"`
 	// Absent is set to true to indicate that the file should not exist. If it
 	// exists, the file is removed.
-	Absent bool
+	Absent bool `yaml:"absent"`
 	// Mode is the file mode and permissions of the file. If not set, defaults to 0644
 	// for files and 0755 for directories.
-	Mode *fs.FileMode
+	Mode *fs.FileMode `yaml:"mode"`
+	// Owner is the user that owns the file, as a name or as a numeric id. If not set,
+	// the owner is not managed. Not supported on Windows.
+	Owner string `yaml:"owner"`
+	// Group is the group that owns the file, as a name or as a numeric id. If not set,
+	// the group is not managed. Not supported on Windows.
+	Group string `yaml:"group"`
 	// Directory is set to true to indicate that the file is a directory.
-	Directory bool
+	Directory bool `yaml:"directory"`
 	// CreateParent is set to true if parent path should be created too.
-	CreateParent bool
+	CreateParent bool `yaml:"create_parent"`
 	// Force forces destructive operations, such as removing a file to replace it
-	// with a directory, or the other way around. These operations will fail if
-	// force is not set.
-	Force bool
-	// Content is the content for the file.
-	// TODO: Support directory contents.
-	Content FileContent
+	// with a directory, or the other way around, or removing directories with
+	// content. These operations will fail if force is not set.
+	Force bool `yaml:"force"`
+	// Content is the content for the file. Use the Directory resource to manage
+	// the content of directories.
+	Content FileContent `yaml:"content"`
 	// KeepExistingContent keeps content of file if it exists.
-	KeepExistingContent bool
+	KeepExistingContent bool `yaml:"keep_existing_content"`
+	// Checksum is the expected checksum of the content of the file. The content is
+	// verified with it before writing it. If the current content of the file matches
+	// this checksum, the file is not updated.
+	Checksum *Checksum `yaml:"checksum"`
 	// MD5 is the expected md5 sum of the content of the file. If the current content
 	// of the file matches this checksum, the file is not updated.
-	MD5 string
+	// Deprecated: Use Checksum instead. It is ignored if Checksum is set.
+	MD5 string `yaml:"md5"`
+	// DependsOn is the list of resources that need to be applied before this file.
+	DependsOn Resources `yaml:"-"`
+	// Notify is the list of handlers to run when this file is created or updated.
+	Notify []*Handler `yaml:"-"`
 }
 
 func (f *File) String() string {
 	return fmt.Sprintf("[File:%s:%s]", f.Provider, f.Path)
 }
 
+// Dependencies returns the resources this file depends on.
+func (f *File) Dependencies() Resources {
+	return f.DependsOn
+}
+
+// Notifications returns the handlers to run when this file changes.
+func (f *File) Notifications() []*Handler {
+	return f.Notify
+}
+
+// Identity returns the identity of the file, formed by the provider and the
+// complete path of the file.
+func (f *File) Identity(scope Scope) ResourceIdentity {
+	provider := f.Provider
+	if provider == "" {
+		provider = defaultFileProviderName
+	}
+	return ResourceIdentity{
+		Type:     "File",
+		Provider: provider,
+		Key:      f.provider(scope).path(f.Path),
+	}
+}
+
+// Snapshot records the existence, content, mode and owner of the file, so they
+// can be restored. Directories with content that would be removed cannot be
+// reverted.
+func (f *File) Snapshot(_ context.Context, scope Scope) (ResourceSnapshot, error) {
+	return snapshotPath(f.provider(scope).fs(), f.Path, f.Absent || !f.Directory)
+}
+
+// Validate checks that the definition of the file is consistent.
+func (f *File) Validate(scope Scope) error {
+	var errs []error
+	if f.Path == "" {
+		errs = append(errs, errors.New("path is required"))
+	} else if err := f.provider(scope).checkPath(f.Path); err != nil {
+		errs = append(errs, err)
+	}
+	if f.Provider != "" {
+		var provider *FileProvider
+		if !scope.Provider(f.Provider, &provider) {
+			errs = append(errs, fmt.Errorf("file provider %q not found", f.Provider))
+		}
+	}
+	if f.Directory && f.Content != nil {
+		errs = append(errs, errors.New("directories cannot have content, use the Directory resource to manage their content"))
+	}
+	if f.Directory && f.checksum() != nil {
+		errs = append(errs, errors.New("directories cannot have checksum"))
+	}
+	if f.Absent && f.CreateParent {
+		errs = append(errs, errors.New("absent files cannot create their parent directories"))
+	}
+	if f.Absent && f.Content != nil {
+		errs = append(errs, errors.New("absent files cannot have content"))
+	}
+	if f.Checksum != nil {
+		if err := f.Checksum.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (f *File) provider(scope Scope) *FileProvider {
-	name := f.Provider
+	return fileProvider(scope, f.Provider)
+}
+
+// fileProvider obtains the file provider with the given name from the scope. If
+// no name is given, the default one is used.
+func fileProvider(scope Scope, name string) *FileProvider {
 	if name == "" {
 		name = defaultFileProviderName
 	}
@@ -87,6 +226,18 @@ func (f *File) provider(scope Scope) *FileProvider {
 	return provider
 }
 
+// checksum returns the expected checksum of the content, if any.
+func (f *File) checksum() *Checksum {
+	switch {
+	case f.Checksum != nil:
+		return f.Checksum
+	case f.MD5 != "":
+		return md5Checksum(f.MD5)
+	default:
+		return nil
+	}
+}
+
 func (f *File) mode() fs.FileMode {
 	switch {
 	case f.Mode != nil:
@@ -99,9 +250,8 @@ func (f *File) mode() fs.FileMode {
 }
 
 func (f *File) Get(ctx context.Context, scope Scope) (current ResourceState, err error) {
-	provider := f.provider(scope)
-	path := filepath.Join(provider.Prefix, f.Path)
-	info, err := os.Stat(path)
+	fsys := f.provider(scope).fs()
+	info, err := fsys.Stat(f.Path)
 	if errors.Is(err, fs.ErrNotExist) {
 		return &FileState{expected: !f.Absent}, nil
 	} else if err != nil {
@@ -112,7 +262,7 @@ func (f *File) Get(ctx context.Context, scope Scope) (current ResourceState, err
 		expected: !f.Absent,
 		scope:    scope,
 		content: func() (io.ReadCloser, error) {
-			return os.Open(path)
+			return fsys.Open(f.Path)
 		},
 	}, nil
 }
@@ -126,6 +276,10 @@ func (f *File) Create(ctx context.Context, scope Scope) error {
 	if err != nil {
 		return err
 	}
+	err = f.ensureOwner(scope)
+	if err != nil {
+		return err
+	}
 	err = f.ensureMode(scope)
 	if err != nil {
 		return err
@@ -134,21 +288,25 @@ func (f *File) Create(ctx context.Context, scope Scope) error {
 }
 
 func (f *File) createFile(scope Scope) error {
-	provider := f.provider(scope)
-	path := filepath.Join(provider.Prefix, f.Path)
+	fsys := f.provider(scope).fs()
 
 	if f.CreateParent {
-		err := os.MkdirAll(filepath.Dir(path), f.mode()|0111)
+		err := fsys.MkdirAll(filepath.Dir(f.Path), f.mode()|0111)
 		if err != nil {
 			return fmt.Errorf("failed to create parent directory: %w", err)
 		}
 	}
 
 	if f.Directory {
-		return os.Mkdir(path, f.mode())
+		return fsys.Mkdir(f.Path, f.mode())
 	}
 
-	created, err := os.OpenFile(path, os.O_CREATE, 0644)
+	// Files with content are created when writing it.
+	if f.Content != nil {
+		return nil
+	}
+
+	created, err := fsys.OpenFile(f.Path, os.O_CREATE, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
@@ -163,67 +321,204 @@ func (f *File) writeContent(ctx context.Context, scope Scope) error {
 	}
 
 	provider := f.provider(scope)
-	path := filepath.Join(provider.Prefix, f.Path)
-
-	return safeWriteContent(ctx, scope, path, f.Content, f.MD5)
+	uid, gid, err := resolveOwner(provider.owners(), f.Owner, f.Group)
+	if err != nil {
+		return err
+	}
+	return safeWriteContent(ctx, scope, provider.fs(), f.Path, f.Content, fileWriteOptions{
+		mode:     f.mode(),
+		uid:      uid,
+		gid:      gid,
+		checksum: f.checksum(),
+		backup: func() error {
+			return provider.backup(ctx, f.Path)
+		},
+	})
 }
 
 func (f *File) ensureMode(scope Scope) error {
-	provider := f.provider(scope)
-	path := filepath.Join(provider.Prefix, f.Path)
-
-	if err := os.Chmod(path, f.mode()); err != nil {
+	fsys := f.provider(scope).fs()
+	if err := fsys.Chmod(f.Path, f.mode()); err != nil {
 		return fmt.Errorf("failed to set mode: %w", err)
 	}
 
 	return nil
 }
 
-// safeWriteContent writes the content to a tmp file before overwriting the original file.
-// If md5sum is not empty, it checks that the md5 is correct before writing the final file.
-func safeWriteContent(ctx context.Context, scope Scope, path string, content FileContent, md5Sum string) error {
-	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path))
+func (f *File) ensureOwner(scope Scope) error {
+	if f.Owner == "" && f.Group == "" {
+		return nil
+	}
+
+	provider := f.provider(scope)
+	uid, gid, err := resolveOwner(provider.owners(), f.Owner, f.Group)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(tmpFile.Name())
+	if err := provider.fs().Lchown(f.Path, uid, gid); err != nil {
+		return fmt.Errorf("failed to set owner: %w", err)
+	}
 
-	checksum := md5.New()
-	w := io.MultiWriter(tmpFile, checksum)
-	err = content(ctx, scope, w)
-	tmpFile.Close()
+	return nil
+}
+
+// fileWriteOptions are the options used to write the content of files.
+type fileWriteOptions struct {
+	// mode is the mode of the written file.
+	mode fs.FileMode
+
+	// uid and gid are the owner and group of the written file. If -1, the
+	// ones of the replaced file are kept.
+	uid, gid int
+
+	// checksum, if not nil, is verified before replacing the file.
+	checksum *Checksum
+
+	// backup, if not nil, is called before replacing an existing file with
+	// different content.
+	backup func() error
+}
+
+// safeWriteContent atomically replaces the named file with the content. The content
+// is written to a temporary file, created with the final mode and owner, that is
+// synced to disk and renamed over the original file. Readers never see a missing or
+// partially written file.
+func safeWriteContent(ctx context.Context, scope Scope, fsys WritableFS, name string, content FileContent, options fileWriteOptions) error {
+	var h hash.Hash
+	if options.checksum != nil {
+		var err error
+		h, err = options.checksum.newHash()
+		if err != nil {
+			return err
+		}
+	}
+
+	tmpFile, err := fsys.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
 	if err != nil {
 		return err
 	}
+	defer fsys.Remove(tmpFile.Name())
+	defer tmpFile.Close()
 
-	if md5Sum != "" && md5Sum != string(checksum.Sum(nil)) {
-		return errors.New("md5 checksum of content differs")
+	if err := tmpFile.Chmod(options.mode); err != nil {
+		return fmt.Errorf("failed to set mode: %w", err)
+	}
+	if err := chownReplacement(fsys, tmpFile, name, options.uid, options.gid); err != nil {
+		return err
 	}
 
-	err = os.Remove(path)
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("cannot replace file %s", path)
+	var w io.Writer = tmpFile
+	if h != nil {
+		w = io.MultiWriter(tmpFile, h)
+	}
+	if err := content(ctx, scope, w); err != nil {
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
 	}
-	return os.Rename(tmpFile.Name(), path)
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if options.checksum != nil {
+		if err := options.checksum.verify(h); err != nil {
+			return err
+		}
+	}
+
+	if options.backup != nil {
+		same, err := sameContent(fsys, name, tmpFile.Name())
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil && !same {
+			if err := options.backup(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := fsys.Rename(tmpFile.Name(), name); err != nil {
+		return fmt.Errorf("cannot replace file %s: %w", name, err)
+	}
+	if err := fsys.SyncDir(filepath.Dir(name)); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// chownReplacement sets the owner of a file that is going to replace the named file.
+// If uid or gid are -1, the ones of the replaced file are kept if it exists. Errors
+// are only returned when the owner or the group are explicitly requested, as
+// unprivileged processes cannot keep the ownership of files owned by other users.
+func chownReplacement(fsys WritableFS, f WritableFile, name string, uid, gid int) error {
+	if uid >= 0 || gid >= 0 {
+		if err := f.Chown(uid, gid); err != nil {
+			return fmt.Errorf("failed to set owner: %w", err)
+		}
+	}
+	if uid >= 0 && gid >= 0 {
+		return nil
+	}
+
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil
+	}
+	currentUID, currentGID, found := fileOwner(info)
+	if !found {
+		return nil
+	}
+	tmpInfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	tmpUID, tmpGID, _ := fileOwner(tmpInfo)
+	if uid >= 0 || tmpUID == currentUID {
+		currentUID = -1
+	}
+	if gid >= 0 || tmpGID == currentGID {
+		currentGID = -1
+	}
+	if currentUID < 0 && currentGID < 0 {
+		return nil
+	}
+	// Keeping the ownership is best effort.
+	f.Chown(currentUID, currentGID)
+	return nil
 }
 
 func (f *File) Update(ctx context.Context, scope Scope) error {
 	provider := f.provider(scope)
-	path := filepath.Join(provider.Prefix, f.Path)
+	fsys := provider.fs()
 	if f.Absent {
-		return os.Remove(path)
+		if err := provider.backup(ctx, f.Path); err != nil {
+			return err
+		}
+		if f.Force {
+			return fsys.RemoveAll(f.Path)
+		}
+		return fsys.Remove(f.Path)
 	}
 
 	if f.Force {
-		info, err := os.Stat(path)
+		// Files are only recreated if their type changes, otherwise they are
+		// updated as usual.
+		info, err := fsys.Stat(f.Path)
 		if err == nil && info != nil && f.Directory != info.IsDir() {
-			err := os.RemoveAll(path)
+			if err := provider.backup(ctx, f.Path); err != nil {
+				return err
+			}
+			err := fsys.RemoveAll(f.Path)
 			if err != nil {
 				return err
 			}
+			return f.Create(ctx, scope)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return f.Create(ctx, scope)
 		}
-
-		return f.Create(ctx, scope)
 	}
 
 	if !f.KeepExistingContent {
@@ -233,7 +528,12 @@ func (f *File) Update(ctx context.Context, scope Scope) error {
 		}
 	}
 
-	err := f.ensureMode(scope)
+	err := f.ensureOwner(scope)
+	if err != nil {
+		return err
+	}
+
+	err = f.ensureMode(scope)
 	if err != nil {
 		return err
 	}
@@ -246,6 +546,12 @@ type FileState struct {
 	expected bool
 	scope    Scope
 	content  func() (io.ReadCloser, error)
+
+	// checking if the file needs update, to report their differences. They are
+	// only kept when diffs are enabled.
+	// checking if the file needs update, to report their differences.
+	currentContent  *diffBuffer
+	expectedContent *diffBuffer
 }
 
 func (f *FileState) Found(context.Context) bool {
@@ -254,36 +560,139 @@ func (f *FileState) Found(context.Context) bool {
 
 func (f *FileState) NeedsUpdate(ctx context.Context, resource Resource) (bool, error) {
 	file := resource.(*File)
-	if file.Absent && f.info != nil {
-		return true, nil
+	if f.info == nil {
+		return false, nil
 	}
-	if f.info != nil && file.Directory != f.info.IsDir() {
+	if file.Absent {
 		return true, nil
 	}
-	// TODO: Implement file permissions support based on ACLs in Windows.
-	if f.info != nil && runtime.GOOS != "windows" && file.mode().Perm() != f.info.Mode().Perm() {
+	if file.Directory != f.info.IsDir() {
 		return true, nil
 	}
+
+	needsUpdate := f.modeChanged(file)
+	if file.Owner != "" || file.Group != "" {
+		changed, err := f.ownerChanged(file)
+		if err != nil {
+			return true, err
+		}
+		needsUpdate = needsUpdate || changed
+	}
 	if file.Content != nil && !file.KeepExistingContent {
-		current, err := f.content()
+		changed, err := f.contentChanged(ctx, file)
 		if err != nil {
 			return true, err
 		}
-		defer current.Close()
+		needsUpdate = needsUpdate || changed
+	}
+	return needsUpdate, nil
+}
 
-		currentCheckSum := md5.New()
-		io.Copy(currentCheckSum, current)
-		if file.MD5 != "" && file.MD5 == string(currentCheckSum.Sum(nil)) {
-			return false, nil
-		}
+// modeChanged returns true if the permissions of the file are different to the
+// ones in the definition.
+func (f *FileState) modeChanged(file *File) bool {
+	// TODO: Implement file permissions support based on ACLs in Windows.
+	return runtime.GOOS != "windows" && file.mode().Perm() != f.info.Mode().Perm()
+}
 
-		expectedCheckSum := md5.New()
-		file.Content(ctx, f.scope, expectedCheckSum)
-		if !bytes.Equal(currentCheckSum.Sum(nil), expectedCheckSum.Sum(nil)) {
-			return true, nil
-		}
+// contentChanged returns true if the content of the file is different to the
+// content in the definition. If the definition has a checksum and the current
+// content matches it, the content is not compared. Contents are only kept to
+// report their differences if diffs are enabled in the context.
+func (f *FileState) contentChanged(ctx context.Context, file *File) (bool, error) {
+	current, err := f.content()
+	if err != nil {
+		return true, err
+	}
+	defer current.Close()
+
+	checksum := file.checksum()
+	if checksum == nil {
+		checksum = &Checksum{Algorithm: ChecksumSHA256}
+	}
+	currentCheckSum, err := checksum.newHash()
+	if err != nil {
+		return true, err
+	}
+	var currentContent, expectedContent *diffBuffer
+	currentWriter := io.Writer(currentCheckSum)
+	if diffsEnabled(ctx) {
+		currentContent, expectedContent = &diffBuffer{}, &diffBuffer{}
+		currentWriter = io.MultiWriter(currentCheckSum, currentContent)
+	}
+	_, err = io.Copy(currentWriter, current)
+	if err != nil {
+		return true, err
+	}
+	if checksum.Hex != "" && checksum.verify(currentCheckSum) == nil {
+		return false, nil
+	}
+
+	expectedCheckSum, _ := checksum.newHash()
+	expectedWriter := io.Writer(expectedCheckSum)
+	if expectedContent != nil {
+		expectedWriter = io.MultiWriter(expectedCheckSum, expectedContent)
+	}
+	err = file.Content(ctx, f.scope, expectedWriter)
+	if err != nil {
+		return true, fmt.Errorf("failed to obtain content: %w", err)
+	}
+	if bytes.Equal(currentCheckSum.Sum(nil), expectedCheckSum.Sum(nil)) {
+		return false, nil
+	}
+
+	f.currentContent = currentContent
+	f.expectedContent = expectedContent
+	return true, nil
+}
+
+// Diff returns the differences between the file and its definition. It includes
+// changes in type and mode, and a unified diff of the content for text files
+// whose content was compared when checking if the file needs update.
+func (f *FileState) Diff(ctx context.Context, resource Resource) (string, error) {
+	file := resource.(*File)
+	if f.info == nil {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	switch {
+	case file.Absent:
+		fmt.Fprintf(&sb, "deleted %s\n", fileType(f.info.IsDir()))
+		return sb.String(), nil
+	case file.Directory != f.info.IsDir():
+		fmt.Fprintf(&sb, "old type %s\nnew type %s\n", fileType(f.info.IsDir()), fileType(file.Directory))
+	}
+	if f.modeChanged(file) {
+		fmt.Fprintf(&sb, "old mode %04o\nnew mode %04o\n", f.info.Mode().Perm(), file.mode().Perm())
+	}
+	if f.currentContent != nil && f.expectedContent != nil {
+		name := strings.TrimPrefix(filepath.ToSlash(file.Path), "/")
+		sb.WriteString(contentDiff(name, f.currentContent, f.expectedContent))
+	}
+	return sb.String(), nil
+}
+
+func fileType(directory bool) string {
+	if directory {
+		return "directory"
+	}
+	return "file"
+}
+
+// ownerChanged returns true if the owner or the group of the file are different
+// to the ones in the definition. Ownership is ignored if it cannot be obtained
+// from the current file.
+func (f *FileState) ownerChanged(file *File) (bool, error) {
+	uid, gid, found := fileOwner(f.info)
+	if !found {
+		return false, nil
+	}
+	expectedUID, expectedGID, err := resolveOwner(file.provider(f.scope).owners(), file.Owner, file.Group)
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	return (expectedUID >= 0 && expectedUID != uid) || (expectedGID >= 0 && expectedGID != gid), nil
 }
 
 // FileMode is a helper function to create a *fs.FileMode inline.
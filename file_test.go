@@ -20,6 +20,8 @@ package resource
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -403,6 +405,38 @@ func TestFileDirectory(t *testing.T) {
 	assert.True(t, info.IsDir())
 }
 
+func TestFileInDirectoryDependency(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	dir := File{
+		Provider:  providerName,
+		Path:      "/dir",
+		Directory: true,
+	}
+	file := File{
+		Provider:  providerName,
+		Path:      "/dir/sample-file.txt",
+		DependsOn: Resources{&dir},
+	}
+	resources := Resources{&file, &dir}
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 2) {
+		assert.Equal(t, &dir, result[0].resource)
+		assert.Equal(t, &file, result[1].resource)
+	}
+
+	_, err = os.Stat(filepath.Join(provider.Prefix, file.Path))
+	assert.NoError(t, err)
+}
+
 func TestFileToDirectoryUpdate(t *testing.T) {
 	providerName := "test-files"
 	provider := FileProvider{
@@ -526,3 +560,103 @@ func assertEqualFileMode(t *testing.T, expected, found os.FileMode) bool {
 	t.Helper()
 	return assert.Equal(t, expected.String(), found.String())
 }
+
+func TestFileContentReplaceIsAtomic(t *testing.T) {
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	path := filepath.Join(provider.Prefix, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content 0"), 0644))
+
+	done := make(chan struct{})
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(readErr)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			d, err := os.ReadFile(path)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if len(d) != len("content 0") {
+				readErr <- fmt.Errorf("partial content read: %q", d)
+				return
+			}
+		}
+	}()
+
+	for i := 1; i <= 50; i++ {
+		resource := File{
+			Provider: providerName,
+			Path:     "/config.txt",
+			Content:  FileContentLiteral(fmt.Sprintf("content %d", i%10)),
+		}
+		_, err := manager.Apply(Resources{&resource})
+		require.NoError(t, err)
+	}
+	close(done)
+	assert.NoError(t, <-readErr)
+}
+
+func TestSafeWriteContentMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("old content"), 0644))
+
+	content := func(ctx context.Context, scope Scope, w io.Writer) error {
+		f, ok := w.(WritableFile)
+		require.True(t, ok)
+		info, err := f.Stat()
+		require.NoError(t, err)
+		assertEqualFileMode(t, fs.FileMode(0640), info.Mode())
+
+		_, err = io.WriteString(w, "new content")
+		return err
+	}
+	err := safeWriteContent(context.Background(), NewManager(), osFS{}, path, content, fileWriteOptions{
+		mode: 0640,
+		uid:  -1,
+		gid:  -1,
+	})
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assertEqualFileMode(t, fs.FileMode(0640), info.Mode())
+}
+
+func TestSafeWriteContentChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.txt")
+	require.NoError(t, os.WriteFile(path, []byte("old content"), 0644))
+
+	checksum, err := ParseChecksum("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	require.NoError(t, err)
+	err = safeWriteContent(context.Background(), NewManager(), osFS{}, path, FileContentLiteral("new content"), fileWriteOptions{
+		mode:     0644,
+		uid:      -1,
+		gid:      -1,
+		checksum: checksum,
+	})
+	require.Error(t, err)
+
+	d, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old content", string(d))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file should be removed")
+}
@@ -21,6 +21,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+
+	"go.yaml.in/yaml/v3"
 )
 
 // FileContent defines the content of a file. It recives an apply context
@@ -35,3 +37,14 @@ func FileContentLiteral(content string) FileContent {
 		return err
 	}
 }
+
+// UnmarshalYAML decodes a literal file content from a YAML string, so contents can
+// be defined in declarative definitions of resources.
+func (c *FileContent) UnmarshalYAML(node *yaml.Node) error {
+	var content string
+	if err := node.Decode(&content); err != nil {
+		return err
+	}
+	*c = FileContentLiteral(content)
+	return nil
+}
@@ -0,0 +1,313 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// Formats supported by FileFacter.
+const (
+	FileFacterJSON   = "json"
+	FileFacterYAML   = "yaml"
+	FileFacterTOML   = "toml"
+	FileFacterDotenv = "dotenv"
+)
+
+// FileFacter is a facter that gets facts from a file. JSON, YAML, TOML and dotenv
+// files are supported. Nested keys are flattened into names joined by dots, so
+// the value of `{"db": {"port": 5432}}` is available in the "db.port" fact.
+// Elements of lists are available by their index, as in "hosts.0". Values keep
+// their types when obtained with FactValue.
+type FileFacter struct {
+	// Path is the path of the file.
+	Path string
+
+	// Format is the format of the file. If not set, it is detected from the
+	// extension of the file.
+	Format string
+
+	// Reload makes the facter read the file again when it is modified.
+	Reload bool
+
+	mu      sync.Mutex
+	loaded  bool
+	modTime time.Time
+	facts   map[string]any
+	err     error
+}
+
+// NewFileFacter returns a facter with the facts in the given file. It returns
+// an error if the file cannot be read or parsed.
+func NewFileFacter(path string) (*FileFacter, error) {
+	f := &FileFacter{Path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Fact returns the value of a fact obtained from the file if it exists. If not,
+// it returns an empty string and false. Lists and maps are only available with
+// FactValue. If the file cannot be read or parsed, no fact is found, and the
+// error is returned by Err. When reloading, the facts previously read are kept
+// on errors.
+func (f *FileFacter) Fact(name string) (string, bool) {
+	v, found := f.FactValue(name)
+	if !found {
+		return "", false
+	}
+	switch v := v.(type) {
+	case []any, map[string]any:
+		return "", false
+	case nil:
+		return "", true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// FactValue returns the value of a fact obtained from the file if it exists,
+// keeping its type. Nested maps and lists are also available.
+func (f *FileFacter) FactValue(name string) (any, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refresh()
+	v, found := f.facts[name]
+	return v, found
+}
+
+// FactNames returns the names of the facts obtained from the file. Names of maps
+// and lists are not included, only the names of the values they contain.
+func (f *FileFacter) FactNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refresh()
+	var names []string
+	for name, value := range f.facts {
+		switch value.(type) {
+		case []any, map[string]any:
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (f *FileFacter) String() string {
+	return fmt.Sprintf("[FileFacter:%s]", f.Path)
+}
+
+// Err returns the error happened the last time the file was read, if any. The
+// file is read if it was not read before, or if it was modified and Reload is
+// set. Managers fail to plan while there are errors, so stale facts are not used.
+func (f *FileFacter) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refresh()
+	return f.err
+}
+
+// refresh reads the file if it was not read before, or if it was modified and
+// Reload is set.
+func (f *FileFacter) refresh() {
+	if !f.loaded || f.Reload && f.modified() {
+		f.err = f.load()
+	}
+}
+
+// modified returns true if the file has been modified since it was read.
+func (f *FileFacter) modified() bool {
+	info, err := os.Stat(f.Path)
+	return err != nil || !info.ModTime().Equal(f.modTime)
+}
+
+// load reads the facts in the file.
+func (f *FileFacter) load() error {
+	f.loaded = true
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read facts file: %w", err)
+	}
+	d, err := os.ReadFile(f.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read facts file: %w", err)
+	}
+
+	facts, err := parseFactsFile(f.format(), d)
+	if err != nil {
+		return fmt.Errorf("failed to parse facts file %s: %w", f.Path, err)
+	}
+	f.facts = facts
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// format returns the format of the file, detecting it from the extension if
+// not set.
+func (f *FileFacter) format() string {
+	if f.Format != "" {
+		return f.Format
+	}
+	switch strings.ToLower(filepath.Ext(f.Path)) {
+	case ".json":
+		return FileFacterJSON
+	case ".yaml", ".yml":
+		return FileFacterYAML
+	case ".toml":
+		return FileFacterTOML
+	case ".env":
+		return FileFacterDotenv
+	}
+	return ""
+}
+
+// parseFactsFile parses the content of a file in the given format, and returns
+// the flattened facts.
+func parseFactsFile(format string, d []byte) (map[string]any, error) {
+	var content any
+	switch format {
+	case FileFacterJSON:
+		decoder := json.NewDecoder(bytes.NewReader(d))
+		decoder.UseNumber()
+		if err := decoder.Decode(&content); err != nil {
+			return nil, err
+		}
+	case FileFacterYAML:
+		if err := yaml.Unmarshal(d, &content); err != nil {
+			return nil, err
+		}
+	case FileFacterTOML:
+		if err := toml.Unmarshal(d, &content); err != nil {
+			return nil, err
+		}
+	case FileFacterDotenv:
+		return parseDotenv(d)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	facts := make(map[string]any)
+	flattenFacts(facts, "", normalizeFactValue(content))
+	return facts, nil
+}
+
+// normalizeFactValue converts the values obtained from different decoders to
+// the types used by typed facters.
+func normalizeFactValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(value))
+		for k, v := range value {
+			m[k] = normalizeFactValue(v)
+		}
+		return m
+	case map[any]any:
+		m := make(map[string]any, len(value))
+		for k, v := range value {
+			m[fmt.Sprint(k)] = normalizeFactValue(v)
+		}
+		return m
+	case []any:
+		l := make([]any, len(value))
+		for i, v := range value {
+			l[i] = normalizeFactValue(v)
+		}
+		return l
+	case []map[string]any:
+		l := make([]any, len(value))
+		for i, v := range value {
+			l[i] = normalizeFactValue(v)
+		}
+		return l
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	case int:
+		return int64(value)
+	default:
+		return value
+	}
+}
+
+// flattenFacts adds the facts in a normalized value, joining the names of nested
+// keys with dots. Maps and lists are also added.
+func flattenFacts(facts map[string]any, prefix string, value any) {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	if prefix != "" {
+		facts[prefix] = value
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			flattenFacts(facts, key(k), v)
+		}
+	case []any:
+		for i, v := range value {
+			flattenFacts(facts, key(strconv.Itoa(i)), v)
+		}
+	}
+}
+
+// parseDotenv parses the variables defined in a dotenv file.
+func parseDotenv(d []byte) (map[string]any, error) {
+	facts := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(d))
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i)
+		}
+		facts[key] = unquoteValue(strings.TrimSpace(value))
+	}
+	return facts, scanner.Err()
+}
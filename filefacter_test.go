@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileFacterFormats(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "facts.json",
+			content: `{"env": "prod", "db": {"port": 5432, "tls": true}, "hosts": ["a", "b"]}`,
+		},
+		{
+			name: "facts.yml",
+			content: `env: prod
+db:
+  port: 5432
+  tls: true
+hosts:
+  - a
+  - b
+`,
+		},
+		{
+			name: "facts.toml",
+			content: `env = "prod"
+hosts = ["a", "b"]
+
+[db]
+port = 5432
+tls = true
+`,
+		},
+	}
+
+	expected := map[string]string{
+		"env":     "prod",
+		"db.port": "5432",
+		"db.tls":  "true",
+		"hosts.0": "a",
+		"hosts.1": "b",
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), c.name)
+			err := os.WriteFile(path, []byte(c.content), 0644)
+			require.NoError(t, err)
+
+			facter, err := NewFileFacter(path)
+			require.NoError(t, err)
+
+			manager := NewManager()
+			manager.AddFacter(facter)
+			for name, value := range expected {
+				v, found := manager.Fact(name)
+				assert.True(t, found, name)
+				assert.Equal(t, value, v, name)
+			}
+
+			_, found := manager.Fact("db")
+			assert.False(t, found)
+
+			port, err := manager.FactInt("db.port")
+			assert.NoError(t, err)
+			assert.Equal(t, 5432, port)
+			tls, err := manager.FactBool("db.tls")
+			assert.NoError(t, err)
+			assert.True(t, tls)
+			hosts, err := manager.FactList("hosts")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b"}, hosts)
+
+			assert.ElementsMatch(t, []string{"env", "db.port", "db.tls", "hosts.0", "hosts.1"}, facter.FactNames())
+
+			db, found := manager.FactValue("db")
+			assert.True(t, found)
+			assert.Equal(t, map[string]any{"port": int64(5432), "tls": true}, db)
+		})
+	}
+}
+
+func TestFileFacterDotenv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings")
+	err := os.WriteFile(path, []byte(`# Settings
+ENV=prod
+export NAME="My App"
+EMPTY=
+`), 0644)
+	require.NoError(t, err)
+
+	facter := &FileFacter{Path: path, Format: FileFacterDotenv}
+	v, found := facter.Fact("ENV")
+	assert.True(t, found)
+	assert.Equal(t, "prod", v)
+
+	v, found = facter.Fact("NAME")
+	assert.True(t, found)
+	assert.Equal(t, "My App", v)
+
+	v, found = facter.Fact("EMPTY")
+	assert.True(t, found)
+	assert.Equal(t, "", v)
+	assert.NoError(t, facter.Err())
+
+	assert.ElementsMatch(t, []string{"ENV", "NAME", "EMPTY"}, facter.FactNames())
+}
+
+func TestFileFacterErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewFileFacter(filepath.Join(dir, "notexists.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	path := filepath.Join(dir, "facts.json")
+	err = os.WriteFile(path, []byte(`{"env": `), 0644)
+	require.NoError(t, err)
+	_, err = NewFileFacter(path)
+	assert.ErrorContains(t, err, "failed to parse facts file")
+
+	path = filepath.Join(dir, "facts.ini")
+	err = os.WriteFile(path, []byte(`env=prod`), 0644)
+	require.NoError(t, err)
+	_, err = NewFileFacter(path)
+	assert.ErrorContains(t, err, `unknown format ""`)
+
+	facter := &FileFacter{Path: filepath.Join(dir, "facts.env"), Format: FileFacterDotenv}
+	_, found := facter.Fact("env")
+	assert.False(t, found)
+	assert.Error(t, facter.Err())
+}
+
+func TestFileFacterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "facts.yaml")
+	err := os.WriteFile(path, []byte(`env: dev`), 0644)
+	require.NoError(t, err)
+
+	facter, err := NewFileFacter(path)
+	require.NoError(t, err)
+	facter.Reload = true
+
+	v, _ := facter.Fact("env")
+	assert.Equal(t, "dev", v)
+
+	modified := time.Now().Add(time.Minute)
+	err = os.WriteFile(path, []byte(`env: prod`), 0644)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	v, _ = facter.Fact("env")
+	assert.Equal(t, "prod", v)
+
+	// Facts are kept if the file cannot be parsed.
+	modified = modified.Add(time.Minute)
+	err = os.WriteFile(path, []byte(`env: [`), 0644)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	v, _ = facter.Fact("env")
+	assert.Equal(t, "prod", v)
+	assert.Error(t, facter.Err())
+}
+
+func TestFileFacterWithoutReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "facts.yaml")
+	err := os.WriteFile(path, []byte(`env: dev`), 0644)
+	require.NoError(t, err)
+
+	facter, err := NewFileFacter(path)
+	require.NoError(t, err)
+
+	modified := time.Now().Add(time.Minute)
+	err = os.WriteFile(path, []byte(`env: prod`), 0644)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	v, _ := facter.Fact("env")
+	assert.Equal(t, "dev", v)
+}
+
+func TestFileFacterErrorsInManager(t *testing.T) {
+	dir := t.TempDir()
+	facter := &FileFacter{Path: filepath.Join(dir, "facts.yml"), Format: FileFacterYAML, Reload: true}
+
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{Prefix: dir})
+	manager.AddFacter(facter)
+
+	_, err := manager.FactBool("enabled")
+	assert.ErrorContains(t, err, `fact "enabled" not found`)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	resources := Resources{&File{Path: "sample.txt"}}
+	_, err = manager.Plan(context.Background(), resources)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "facter [FileFacter:"+facter.Path+"] failed")
+
+	err = os.WriteFile(facter.Path, []byte(`enabled: true`), 0644)
+	require.NoError(t, err)
+	_, err = manager.Plan(context.Background(), resources)
+	assert.NoError(t, err)
+	enabled, err := manager.FactBool("enabled")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+}
+
+func TestFileFacterErrorsInModule(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{Prefix: dir})
+
+	module := &Module{
+		Name: "sample",
+		Facters: []Facter{
+			&FileFacter{Path: filepath.Join(dir, "facts.json"), Format: FileFacterJSON},
+		},
+		Resources: Resources{&File{Path: "sample.txt"}},
+	}
+	_, err := manager.Apply(Resources{module})
+	assert.ErrorContains(t, err, "module sample: facter [FileFacter:")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = os.Stat(filepath.Join(dir, "sample.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"fmt"
+	"io/fs"
+	"os/user"
+	"strconv"
+)
+
+// OwnerLookup resolves names of users and groups to their numeric ids.
+type OwnerLookup interface {
+	// LookupUser returns the numeric id of the user with the given name.
+	LookupUser(name string) (uid int, err error)
+
+	// LookupGroup returns the numeric id of the group with the given name.
+	LookupGroup(name string) (gid int, err error)
+}
+
+// systemOwnerLookup resolves users and groups using the database of the system.
+type systemOwnerLookup struct{}
+
+// LookupUser returns the numeric id of the user with the given name.
+func (systemOwnerLookup) LookupUser(name string) (int, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// LookupGroup returns the numeric id of the group with the given name.
+func (systemOwnerLookup) LookupGroup(name string) (int, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// resolveOwner returns the numeric ids for the given owner and group, that can
+// be names or numeric ids. It returns -1 for empty values.
+func resolveOwner(lookup OwnerLookup, owner, group string) (uid int, gid int, err error) {
+	uid, err = resolveOwnerID(owner, lookup.LookupUser)
+	if err != nil {
+		return -1, -1, fmt.Errorf("failed to resolve owner %q: %w", owner, err)
+	}
+	gid, err = resolveOwnerID(group, lookup.LookupGroup)
+	if err != nil {
+		return -1, -1, fmt.Errorf("failed to resolve group %q: %w", group, err)
+	}
+	return uid, gid, nil
+}
+
+func resolveOwnerID(name string, lookup func(string) (int, error)) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	return lookup(name)
+}
+
+// fileOwner returns the owner and the group of a file, if they can be obtained
+// from its information.
+func fileOwner(info fs.FileInfo) (uid int, gid int, found bool) {
+	if owner, ok := info.Sys().(memFileOwner); ok {
+		return owner.uid, owner.gid, owner.uid >= 0 && owner.gid >= 0
+	}
+	return systemFileOwner(info)
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOwner(t *testing.T) {
+	lookup := fakeOwnerLookup{
+		users:  map[string]int{"someuser": 1000},
+		groups: map[string]int{"somegroup": 2000},
+	}
+
+	cases := []struct {
+		owner, group string
+		uid, gid     int
+		err          bool
+	}{
+		{"", "", -1, -1, false},
+		{"someuser", "", 1000, -1, false},
+		{"", "somegroup", -1, 2000, false},
+		{"someuser", "somegroup", 1000, 2000, false},
+		{"42", "43", 42, 43, false},
+		{"otheruser", "", -1, -1, true},
+		{"", "othergroup", -1, -1, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.owner+":"+c.group, func(t *testing.T) {
+			uid, gid, err := resolveOwner(lookup, c.owner, c.group)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.uid, uid)
+			assert.Equal(t, c.gid, gid)
+		})
+	}
+}
+
+func TestFileOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("File ownership is not supported on Windows")
+	}
+
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Owners: fakeOwnerLookup{
+			users:  map[string]int{"someuser": os.Getuid()},
+			groups: map[string]int{"somegroup": os.Getgid()},
+		},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := File{
+		Path:  "sample-file.txt",
+		Owner: "someuser",
+		Group: "somegroup",
+	}
+	resources := Resources{&resource}
+	path := filepath.Join(provider.Prefix, resource.Path)
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionCreate, result[0].action)
+	}
+	assertFileOwner(t, path, os.Getuid(), os.Getgid())
+
+	// On second apply, it should do nothing.
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	if os.Getuid() != 0 {
+		t.Skip("Changing ownership to other users requires running as root")
+	}
+
+	otherUID, otherGID := 4242, 4343
+	require.NoError(t, os.Lchown(path, otherUID, otherGID))
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+	}
+	assertFileOwner(t, path, os.Getuid(), os.Getgid())
+
+	resource.Owner = strconv.Itoa(otherUID)
+	resource.Group = ""
+	state, err := resource.Get(context.Background(), manager)
+	require.NoError(t, err)
+	needsUpdate, err := state.NeedsUpdate(context.Background(), &resource)
+	require.NoError(t, err)
+	assert.True(t, needsUpdate)
+
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	assertFileOwner(t, path, otherUID, os.Getgid())
+}
+
+func TestFileOwnerNotFound(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+		Owners: fakeOwnerLookup{},
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := File{
+		Path:  "sample-file.txt",
+		Owner: "someuser",
+	}
+
+	result, err := manager.Apply(Resources{&resource})
+	t.Log(result)
+	assert.Error(t, err)
+}
+
+func assertFileOwner(t *testing.T, path string, uid, gid int) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+	foundUID, foundGID, found := fileOwner(info)
+	if assert.True(t, found) {
+		assert.Equal(t, uid, foundUID)
+		assert.Equal(t, gid, foundGID)
+	}
+}
+
+type fakeOwnerLookup struct {
+	users  map[string]int
+	groups map[string]int
+}
+
+func (l fakeOwnerLookup) LookupUser(name string) (int, error) {
+	uid, found := l.users[name]
+	if !found {
+		return -1, fmt.Errorf("unknown user %s", name)
+	}
+	return uid, nil
+}
+
+func (l fakeOwnerLookup) LookupGroup(name string) (int, error) {
+	gid, found := l.groups[name]
+	if !found {
+		return -1, fmt.Errorf("unknown group %s", name)
+	}
+	return gid, nil
+}
@@ -19,7 +19,6 @@ package resource
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
@@ -65,14 +64,22 @@ func (s *SourceFS) File(path string) FileContent {
 // Template returns the file content for a given path in the source file system.
 // If the file contains a template, this template is executed.
 // The template can use the `fact(string) string`  function, as well as other functions
-// defined with `WithTemplateFuncs`.
+// defined with `WithTemplateFuncs`. Structured values of facts can be obtained with
+// the `factValue(string) any` function, as in `{{ range factValue "hosts" }}`.
 func (s *SourceFS) Template(path string) FileContent {
 	return func(_ context.Context, scope Scope, w io.Writer) error {
 		fmap := template.FuncMap{
 			"fact": func(name string) (string, error) {
 				v, found := scope.Fact(name)
 				if !found {
-					return "", fmt.Errorf("fact %q not found", name)
+					return "", errFactNotFound(scope, name)
+				}
+				return v, nil
+			},
+			"factValue": func(name string) (any, error) {
+				v, found := scope.FactValue(name)
+				if !found {
+					return nil, errFactNotFound(scope, name)
 				}
 				return v, nil
 			},
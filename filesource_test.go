@@ -20,7 +20,10 @@ package resource
 import (
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -98,6 +101,31 @@ func TestFileContentFromSourceTemplate(t *testing.T) {
 	}
 }
 
+func TestFileContentFromSourceTemplateFactValue(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.AddFacter(StaticValuesFacter{
+		"hosts": []any{"a", "b"},
+		"db":    map[string]any{"port": 5432},
+	})
+
+	source := NewSourceFS(os.DirFS("testdata/templates"))
+	resource := File{
+		Path:    "/typed-facts.txt",
+		Content: source.Template("typed-facts.txt.tmpl"),
+	}
+	_, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+
+	d, err := os.ReadFile(filepath.Join(provider.Prefix, resource.Path))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a:5432,b:5432\n", string(d))
+	}
+}
+
 func TestFileContentFromSourceURL(t *testing.T) {
 	expectedContent := "Some content from the Internet!"
 	expectedMD5 := md5.Sum([]byte(expectedContent))
@@ -133,3 +161,81 @@ func TestFileContentFromSourceURL(t *testing.T) {
 		assert.Equal(t, expectedContent, string(d))
 	}
 }
+
+func TestFileContentFromSourceURLChecksum(t *testing.T) {
+	expectedContent := "Some content from the Internet!"
+	expectedSHA256 := sha256.Sum256([]byte(expectedContent))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, expectedContent)
+	}))
+	defer server.Close()
+
+	t.Run("valid checksum", func(t *testing.T) {
+		provider := FileProvider{
+			Prefix: t.TempDir(),
+		}
+		manager := NewManager()
+		manager.RegisterProvider(defaultFileProviderName, &provider)
+
+		resource := File{
+			Path:     "/sample-file.txt",
+			Content:  DefaultHTTPSource.Get(server.URL),
+			Checksum: SHA256(hex.EncodeToString(expectedSHA256[:])),
+		}
+
+		result, err := manager.Apply(Resources{&resource})
+		t.Log(result)
+		require.NoError(t, err)
+
+		d, err := os.ReadFile(filepath.Join(provider.Prefix, resource.Path))
+		if assert.NoError(t, err) {
+			assert.Equal(t, expectedContent, string(d))
+		}
+	})
+
+	t.Run("invalid checksum", func(t *testing.T) {
+		provider := FileProvider{
+			Prefix: t.TempDir(),
+		}
+		manager := NewManager()
+		manager.RegisterProvider(defaultFileProviderName, &provider)
+
+		otherSHA256 := sha256.Sum256([]byte("other content"))
+		resource := File{
+			Path:     "/sample-file.txt",
+			Content:  DefaultHTTPSource.Get(server.URL),
+			Checksum: SHA256(hex.EncodeToString(otherSHA256[:])),
+		}
+
+		result, err := manager.Apply(Resources{&resource})
+		t.Log(result)
+		assert.ErrorContains(t, err, "sha256 checksum of content differs")
+
+		d, _ := os.ReadFile(filepath.Join(provider.Prefix, resource.Path))
+		assert.NotEqual(t, expectedContent, string(d))
+	})
+
+	t.Run("checksum of current content matches", func(t *testing.T) {
+		provider := FileProvider{
+			Prefix: t.TempDir(),
+		}
+		manager := NewManager()
+		manager.RegisterProvider(defaultFileProviderName, &provider)
+
+		resource := File{
+			Path: "/sample-file.txt",
+			Content: func(context.Context, Scope, io.Writer) error {
+				t.Fatal("content should not be obtained if checksum matches")
+				return nil
+			},
+			Checksum: SHA256(hex.EncodeToString(expectedSHA256[:])),
+		}
+		err := os.WriteFile(filepath.Join(provider.Prefix, resource.Path), []byte(expectedContent), 0644)
+		require.NoError(t, err)
+
+		result, err := manager.Apply(Resources{&resource})
+		t.Log(result)
+		require.NoError(t, err)
+		assert.Empty(t, result)
+	})
+}
@@ -0,0 +1,147 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DependentResource is implemented by resources that depend on other resources.
+// Dependencies are applied before the resources depending on them, and if they
+// fail, the resources depending on them are skipped.
+type DependentResource interface {
+	Resource
+
+	// Dependencies returns the resources this resource depends on. They must
+	// be part of the same collection of resources.
+	Dependencies() Resources
+}
+
+// resourceNode is a resource in the dependency graph.
+type resourceNode struct {
+	resource     Resource
+	dependencies []*resourceNode
+
+	// scope is the scope used to apply the resource.
+	scope Scope
+
+	// module is the path of the module where the resource is defined.
+	module string
+
+	// err is an error happened while preparing the node, the resource is not
+	// applied if it is set.
+	err error
+}
+
+// resourceGraph builds the dependency graph of a collection of resources, and
+// returns its nodes sorted so dependencies are always before the resources that
+// depend on them. The original order is kept for independent resources.
+// Modules and expandable resources are expanded, resources in modules use a scope derived from the given one.
+// An error is returned if there are dependency cycles, or if some dependency is
+// not part of the collection.
+func resourceGraph(ctx context.Context, resources Resources, scope Scope) ([]*resourceNode, error) {
+	nodes, index, err := expandResources(ctx, resources, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		dependent, ok := asResource[DependentResource](node.resource)
+		if !ok {
+			continue
+		}
+		for _, dependency := range dependent.Dependencies() {
+			var found []*resourceNode
+			var exists bool
+			if isComparableResource(dependency) {
+				found, exists = index[dependency]
+			}
+			if !exists {
+				return nil, fmt.Errorf("resource %s depends on %s, that is not part of the collection", node.resource, dependency)
+			}
+			node.dependencies = append(node.dependencies, found...)
+		}
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[*resourceNode]int)
+	sorted := make([]*resourceNode, 0, len(nodes))
+	var stack []*resourceNode
+	var visit func(node *resourceNode) error
+	visit = func(node *resourceNode) error {
+		switch state[node] {
+		case visited:
+			return nil
+		case visiting:
+			return newDependencyCycleError(stack, node)
+		}
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, dependency := range node.dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+		sorted = append(sorted, node)
+		return nil
+	}
+	for _, node := range nodes {
+		if err := visit(node); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// newDependencyCycleError returns an error describing the cycle found in the
+// stack of visited nodes when reaching the given node again.
+func newDependencyCycleError(stack []*resourceNode, node *resourceNode) error {
+	var cycle []string
+	for i := len(stack) - 1; i >= 0; i-- {
+		cycle = append([]string{fmt.Sprint(stack[i].resource)}, cycle...)
+		if stack[i] == node {
+			break
+		}
+	}
+	cycle = append(cycle, fmt.Sprint(node.resource))
+	return fmt.Errorf("dependency cycle found: %s", strings.Join(cycle, " -> "))
+}
+
+// isComparableResource returns true if the resource can be used as key in maps.
+func isComparableResource(resource Resource) bool {
+	return resource != nil && reflect.TypeOf(resource).Comparable()
+}
+
+// failedDependency returns the first dependency of the node that failed, or nil
+// if none failed.
+func failedDependency(node *resourceNode, failed func(*resourceNode) bool) *resourceNode {
+	for _, dependency := range node.dependencies {
+		if failed(dependency) {
+			return dependency
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceGraph(t *testing.T) {
+	t.Run("keep order of independent resources", func(t *testing.T) {
+		a, b, c := &File{Path: "a"}, &File{Path: "b"}, &File{Path: "c"}
+		nodes, err := resourceGraph(context.Background(), Resources{a, b, c}, NewManager())
+		require.NoError(t, err)
+		assert.Equal(t, Resources{a, b, c}, nodeResources(nodes))
+	})
+	t.Run("dependencies first", func(t *testing.T) {
+		dir := &File{Path: "dir", Directory: true}
+		file := &File{Path: "dir/file", DependsOn: Resources{dir}}
+		other := &File{Path: "other", DependsOn: Resources{file, dir}}
+		nodes, err := resourceGraph(context.Background(), Resources{other, file, dir}, NewManager())
+		require.NoError(t, err)
+		assert.Equal(t, Resources{dir, file, other}, nodeResources(nodes))
+	})
+	t.Run("dependency not in collection", func(t *testing.T) {
+		dir := &File{Path: "dir", Directory: true}
+		file := &File{Path: "dir/file", DependsOn: Resources{dir}}
+		_, err := resourceGraph(context.Background(), Resources{file}, NewManager())
+		assert.ErrorContains(t, err, "not part of the collection")
+	})
+	t.Run("cycle", func(t *testing.T) {
+		a, b, c := &File{Path: "a"}, &File{Path: "b"}, &File{Path: "c"}
+		a.DependsOn = Resources{c}
+		b.DependsOn = Resources{a}
+		c.DependsOn = Resources{b}
+		_, err := resourceGraph(context.Background(), Resources{a, b, c}, NewManager())
+		assert.EqualError(t, err, "dependency cycle found: [File::a] -> [File::c] -> [File::b] -> [File::a]")
+	})
+	t.Run("self dependency", func(t *testing.T) {
+		a := &File{Path: "a"}
+		a.DependsOn = Resources{a}
+		_, err := resourceGraph(context.Background(), Resources{a}, NewManager())
+		assert.EqualError(t, err, "dependency cycle found: [File::a] -> [File::a]")
+	})
+}
+
+func nodeResources(nodes []*resourceNode) Resources {
+	var resources Resources
+	for _, node := range nodes {
+		resources = append(resources, node.resource)
+	}
+	return resources
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Handler is an action that is run when notified by resources that changed. Each
+// handler is run once at the end of the application of a collection of resources,
+// if any of the resources notifying it is created or updated.
+type Handler struct {
+	// Name is the name of the handler.
+	Name string
+	// Run is the function executed when the handler is notified. It receives the
+	// scope of the first resource that notified it.
+	Run func(context.Context, Scope) error
+}
+
+func (h *Handler) String() string {
+	return fmt.Sprintf("[Handler:%s]", h.Name)
+}
+
+// Get returns an error, handlers are run by the manager when notified, they cannot
+// be managed as resources.
+func (h *Handler) Get(context.Context, Scope) (ResourceState, error) {
+	return nil, h.errNotNotified()
+}
+
+// Create returns an error, handlers are run by the manager when notified, they cannot
+// be managed as resources.
+func (h *Handler) Create(context.Context, Scope) error {
+	return h.errNotNotified()
+}
+
+// Update returns an error, handlers are run by the manager when notified, they cannot
+// be managed as resources.
+func (h *Handler) Update(context.Context, Scope) error {
+	return h.errNotNotified()
+}
+
+func (h *Handler) errNotNotified() error {
+	return fmt.Errorf("handler %s can only be notified by other resources", h.Name)
+}
+
+// NotifyingResource is implemented by resources that notify handlers when they
+// are created or updated.
+type NotifyingResource interface {
+	Resource
+
+	// Notifications returns the handlers to notify when the resource changes.
+	Notifications() []*Handler
+}
+
+// runHandlers runs the handlers notified by the resources that were created or
+// updated, in the order they were first notified. It returns the results of
+// the handlers.
+func (m *Manager) runHandlers(ctx context.Context, nodes []*resourceNode, results []*ApplyResult) []*ApplyResult {
+	type notification struct {
+		handler *Handler
+		node    *resourceNode
+	}
+	var notifications []notification
+	for i, result := range results {
+		if result == nil || result.err != nil {
+			continue
+		}
+		if result.action != ActionCreate && result.action != ActionUpdate {
+			continue
+		}
+		notifying, ok := asResource[NotifyingResource](result.resource)
+		if !ok {
+			continue
+		}
+		for _, handler := range notifying.Notifications() {
+			notified := slices.ContainsFunc(notifications, func(n notification) bool {
+				return n.handler == handler
+			})
+			if !notified {
+				notifications = append(notifications, notification{handler: handler, node: nodes[i]})
+			}
+		}
+	}
+
+	var handlerResults []*ApplyResult
+	for _, n := range notifications {
+		result := &ApplyResult{
+			action:   ActionRun,
+			resource: n.handler,
+			module:   n.node.module,
+			reason:   fmt.Sprintf("notified by %s", n.node.resource),
+			started:  time.Now(),
+		}
+		switch {
+		case ctx.Err() != nil:
+			result.action = ActionSkip
+			result.reason = "apply interrupted"
+		case n.handler.Run == nil:
+			result.err = errors.New("handler has no function to run")
+		default:
+			result.err = n.handler.Run(ctx, m.nodeScope(n.node))
+		}
+		result.duration = time.Since(result.started)
+		handlerResults = append(handlerResults, result)
+	}
+	return handlerResults
+}
@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	restarts := 0
+	restart := &Handler{
+		Name: "restart",
+		Run: func(context.Context, Scope) error {
+			restarts++
+			return nil
+		},
+	}
+	resources := Resources{
+		&File{Path: "/docker-compose.yml", Content: FileContentLiteral("services: {}\n"), Notify: []*Handler{restart}},
+		&File{Path: "/.env", Content: FileContentLiteral("FOO=bar\n"), Notify: []*Handler{restart}},
+	}
+
+	results, err := manager.Apply(resources)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, ActionRun, results[2].Action())
+	assert.Equal(t, restart, results[2].Resource())
+	assert.Equal(t, "notified by [File::/docker-compose.yml]", results[2].Reason())
+	assert.Equal(t, 1, restarts)
+
+	// Nothing changes, handlers are not notified.
+	results, err = manager.Apply(resources)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Equal(t, 1, restarts)
+
+	// Only one of the files changes.
+	err = os.WriteFile(filepath.Join(provider.Prefix, ".env"), []byte("FOO=baz\n"), 0644)
+	require.NoError(t, err)
+	results, err = manager.Apply(resources)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, ActionUpdate, results[0].Action())
+	assert.Equal(t, ActionRun, results[1].Action())
+	assert.Equal(t, "notified by [File::/.env]", results[1].Reason())
+	assert.Equal(t, 2, restarts)
+}
+
+func TestHandlersFailed(t *testing.T) {
+	manager := NewManager()
+
+	failing := &Handler{
+		Name: "failing",
+		Run: func(context.Context, Scope) error {
+			return errors.New("cannot restart")
+		},
+	}
+	notRun := &Handler{
+		Name: "not-run",
+		Run: func(context.Context, Scope) error {
+			t.Error("handler notified by failed resource should not run")
+			return nil
+		},
+	}
+	resources := Resources{
+		&notifyingResource{dummyResource: dummyResource{absent: true}, notify: []*Handler{failing}},
+		&notifyingResource{dummyResource: dummyResource{absent: true, createError: errors.New("failed")}, notify: []*Handler{notRun}},
+	}
+
+	results, err := manager.Apply(resources)
+	assert.Error(t, err)
+	require.Len(t, results, 3)
+	assert.Error(t, results[1].Err())
+	assert.Equal(t, ActionRun, results[2].Action())
+	assert.ErrorContains(t, results[2].Err(), "cannot restart")
+}
+
+func TestHandlerNotManaged(t *testing.T) {
+	manager := NewManager()
+	results, err := manager.Apply(Resources{&Handler{Name: "restart"}})
+	assert.ErrorContains(t, err, "handler restart can only be notified by other resources")
+	require.Len(t, results, 1)
+}
+
+type notifyingResource struct {
+	dummyResource
+	notify []*Handler
+}
+
+func (r *notifyingResource) Notifications() []*Handler { return r.notify }
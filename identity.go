@@ -0,0 +1,260 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// IdentifiableResource is implemented by resources that have an identity. Only
+// one definition is allowed for each identity in a collection of resources.
+// Identical definitions are merged, and different ones are reported as conflicts.
+type IdentifiableResource interface {
+	Resource
+
+	// Identity returns the identity of the resource in the given scope.
+	Identity(Scope) ResourceIdentity
+}
+
+// ResourceIdentity identifies the managed object of a resource. It is formed by
+// the type of the resource, the provider used to manage it and a key, unique for
+// the type and provider.
+type ResourceIdentity struct {
+	Type     string
+	Provider string
+	Key      string
+}
+
+func (i ResourceIdentity) String() string {
+	return fmt.Sprintf("[%s:%s:%s]", i.Type, i.Provider, i.Key)
+}
+
+// mergeDuplicates returns the nodes without duplicated definitions for the same
+// identity. Dependencies on duplicated nodes are replaced by dependencies on the
+// first definition. It returns an error if there are different definitions for
+// the same identity. Nodes excluded by the filter of the manager, or whose
+// condition is not met, are not considered.
+func (m *Manager) mergeDuplicates(ctx context.Context, nodes []*resourceNode) ([]*resourceNode, error) {
+	definitions := make(map[ResourceIdentity]*resourceNode)
+	duplicates := make(map[*resourceNode]*resourceNode)
+	var errs []error
+	for _, node := range nodes {
+		if !m.selected(node) {
+			continue
+		}
+		identifiable, ok := asResource[IdentifiableResource](node.resource)
+		if !ok {
+			continue
+		}
+		identity := identifiable.Identity(m.nodeScope(node))
+		first, found := definitions[identity]
+		if !found {
+			definitions[identity] = node
+			continue
+		}
+		identical, err := m.identicalNodes(ctx, first, node)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compare definitions for %s: %w", identity, err))
+			continue
+		}
+		if !identical {
+			errs = append(errs, fmt.Errorf("conflicting definitions for %s: %s and %s", identity, nodeDescription(first), nodeDescription(node)))
+			continue
+		}
+		duplicates[node] = first
+	}
+	if len(errs) > 0 {
+		return nil, newApplyError(errs)
+	}
+	if len(duplicates) == 0 {
+		return nodes, nil
+	}
+
+	merged := make([]*resourceNode, 0, len(nodes)-len(duplicates))
+	for _, node := range nodes {
+		if _, found := duplicates[node]; found {
+			continue
+		}
+		var dependencies []*resourceNode
+		for _, dependency := range node.dependencies {
+			if first, found := duplicates[dependency]; found {
+				dependency = first
+			}
+			if !slices.Contains(dependencies, dependency) {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+		node.dependencies = dependencies
+		merged = append(merged, node)
+	}
+	return merged, nil
+}
+
+// identicalNodes returns true if the resources of both nodes have the same
+// definition. File contents are compared by their checksums when both files have
+// the same one, and by rendering them in the scope of each node otherwise. Other
+// function fields are only identical if they are not set.
+func (m *Manager) identicalNodes(ctx context.Context, a, b *resourceNode) (bool, error) {
+	if isComparableResource(a.resource) && isComparableResource(b.resource) && a.resource == b.resource {
+		return true, nil
+	}
+	comparer := resourceComparer{
+		ctx:     ctx,
+		scopes:  [2]Scope{m.nodeScope(a), m.nodeScope(b)},
+		visited: make(map[[2]uintptr]bool),
+	}
+	return comparer.equal(reflect.ValueOf(a.resource), reflect.ValueOf(b.resource))
+}
+
+var fileContentType = reflect.TypeFor[FileContent]()
+
+// resourceComparer compares the definitions of resources, as reflect.DeepEqual,
+// but rendering their file contents to compare them.
+type resourceComparer struct {
+	ctx    context.Context
+	scopes [2]Scope
+
+	// visited keeps the pairs of pointers already compared, or being compared, to
+	// avoid cycles.
+	visited map[[2]uintptr]bool
+}
+
+func (c *resourceComparer) equal(a, b reflect.Value) (bool, error) {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid(), nil
+	}
+	if a.Type() != b.Type() {
+		return false, nil
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() || a.Pointer() == b.Pointer() {
+			return a.Pointer() == b.Pointer(), nil
+		}
+		if file, ok := c.files(a, b); ok {
+			return c.equalFiles(file[0], file[1])
+		}
+		key := [2]uintptr{a.Pointer(), b.Pointer()}
+		if c.visited[key] {
+			return true, nil
+		}
+		c.visited[key] = true
+		return c.equal(a.Elem(), b.Elem())
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil(), nil
+		}
+		return c.equal(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := range a.NumField() {
+			equal, err := c.equal(a.Field(i), b.Field(i))
+			if !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice && (a.IsNil() != b.IsNil() || a.Len() != b.Len()) {
+			return false, nil
+		}
+		for i := range a.Len() {
+			equal, err := c.equal(a.Index(i), b.Index(i))
+			if !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false, nil
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			value := b.MapIndex(iter.Key())
+			if !value.IsValid() {
+				return false, nil
+			}
+			equal, err := c.equal(iter.Value(), value)
+			if !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case reflect.Func:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil(), nil
+		}
+		if a.Type() != fileContentType || !a.CanInterface() {
+			return false, nil
+		}
+		return c.equalContents(a.Interface().(FileContent), b.Interface().(FileContent))
+	case reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer(), nil
+	default:
+		return a.Equal(b), nil
+	}
+}
+
+// files returns the files pointed by both values, if they are files.
+func (c *resourceComparer) files(a, b reflect.Value) ([2]*File, bool) {
+	if !a.CanInterface() {
+		return [2]*File{}, false
+	}
+	fileA, ok := a.Interface().(*File)
+	if !ok {
+		return [2]*File{}, false
+	}
+	return [2]*File{fileA, b.Interface().(*File)}, true
+}
+
+// equalFiles compares two files. Their contents are not compared if both have
+// the same checksum.
+func (c *resourceComparer) equalFiles(a, b *File) (bool, error) {
+	checksumA, checksumB := a.checksum(), b.checksum()
+	if checksumA != nil && checksumB != nil && *checksumA == *checksumB {
+		copyA, copyB := *a, *b
+		copyA.Content, copyB.Content = nil, nil
+		return c.equal(reflect.ValueOf(copyA), reflect.ValueOf(copyB))
+	}
+	return c.equal(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem())
+}
+
+// equalContents renders both contents, each one in its scope, and compares them.
+func (c *resourceComparer) equalContents(a, b FileContent) (bool, error) {
+	var bufA, bufB bytes.Buffer
+	if err := a(c.ctx, c.scopes[0], &bufA); err != nil {
+		return false, err
+	}
+	if err := b(c.ctx, c.scopes[1], &bufB); err != nil {
+		return false, err
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes()), nil
+}
+
+// nodeDescription returns a description of a node for error messages.
+func nodeDescription(node *resourceNode) string {
+	if node.module == "" {
+		return fmt.Sprint(node.resource)
+	}
+	return fmt.Sprintf("%s in module %s", node.resource, node.module)
+}
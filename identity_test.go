@@ -0,0 +1,199 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictingResources(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	resources := Resources{
+		&File{Path: "/other.txt"},
+		&File{Path: "/foo.txt", Mode: FileMode(0644)},
+		&File{Provider: "file", Path: "foo.txt", Mode: FileMode(0600)},
+	}
+	results, err := manager.Apply(resources)
+	assert.Empty(t, results)
+	assert.ErrorContains(t, err, "conflicting definitions for [File:file:"+filepath.Join(provider.Prefix, "foo.txt")+"]: [File::/foo.txt] and [File:file:foo.txt]")
+
+	_, err = os.Stat(filepath.Join(provider.Prefix, "other.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "no resource should be applied")
+}
+
+func TestDuplicatedResourcesMerged(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	shared := &File{Path: "/shared.txt", Content: FileContentLiteral("shared")}
+	dir := &File{Path: "/dir", Directory: true}
+	duplicatedDir := &File{Path: "/dir", Directory: true}
+	resources := Resources{
+		dir,
+		shared,
+		duplicatedDir,
+		shared,
+		&File{Path: "/dir/foo.txt", DependsOn: Resources{duplicatedDir}},
+	}
+
+	results, err := manager.Apply(resources)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, dir, results[0].Resource())
+	assert.Equal(t, shared, results[1].Resource())
+	assert.Equal(t, resources[4], results[2].Resource())
+	assert.FileExists(t, filepath.Join(provider.Prefix, "dir", "foo.txt"))
+}
+
+func TestConditionalDefinitionsNotConflicting(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.AddFacter(StaticFacter{"os": "linux"})
+
+	resources := Resources{
+		When(FactEquals("os", "linux"), &File{Path: "/config", Content: FileContentLiteral("linux")}),
+		When(FactEquals("os", "darwin"), &File{Path: "/config", Content: FileContentLiteral("darwin")}),
+	}
+	results, err := manager.Apply(resources)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, ActionCreate, results[0].Action())
+	assert.Equal(t, ActionSkip, results[1].Action())
+
+	d, err := os.ReadFile(filepath.Join(provider.Prefix, "config"))
+	require.NoError(t, err)
+	assert.Equal(t, "linux", string(d))
+}
+
+func TestModulesWithDifferentProvidersNotConflicting(t *testing.T) {
+	manager := NewManager()
+	module := func(name string, prefix string) *Module {
+		return &Module{
+			Name: name,
+			Providers: map[string]Provider{
+				"file": &FileProvider{Prefix: prefix},
+			},
+			Resources: Resources{
+				&File{Path: "/config", Content: FileContentLiteral(name)},
+			},
+		}
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	results, err := manager.Apply(Resources{
+		module("first", first),
+		module("second", second),
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	conflicting := Resources{
+		module("first", first),
+		module("second", first),
+	}
+	_, err = manager.Apply(conflicting)
+	assert.ErrorContains(t, err, "[File::/config] in module first and [File::/config] in module second")
+}
+
+func TestIdenticalResourcesInModulesMerged(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	funcs := template.FuncMap{
+		"sayHello": func() string { return "Hello!" },
+	}
+	source := NewSourceFS(os.DirFS("testdata/templates")).WithTemplateFuncs(funcs)
+	module := func(name, fact string) *Module {
+		return &Module{
+			Name:   name,
+			Params: map[string]string{"sample": fact},
+			Resources: Resources{
+				&File{Path: "/config", Content: FileContentLiteral("same")},
+				&File{Path: "/template", Content: source.Template("sample-file.txt.tmpl")},
+			},
+		}
+	}
+
+	results, err := manager.Apply(Resources{
+		module("first", "fact"),
+		module("second", "fact"),
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	d, err := os.ReadFile(filepath.Join(provider.Prefix, "template"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello! This is a template with a fact: fact\n", string(d))
+
+	// Templates rendered differently in each module are conflicting.
+	_, err = manager.Apply(Resources{
+		module("first", "fact"),
+		module("second", "other"),
+	})
+	assert.ErrorContains(t, err, "[File::/template] in module first and [File::/template] in module second")
+	assert.NotContains(t, err.Error(), "[File::/config]")
+}
+
+func TestIdenticalNodesWithChecksum(t *testing.T) {
+	manager := NewManager()
+	failing := func(context.Context, Scope, io.Writer) error {
+		return errors.New("content not available")
+	}
+	node := func(checksum *Checksum) *resourceNode {
+		return &resourceNode{resource: &File{Path: "/foo", Content: failing, Checksum: checksum}}
+	}
+
+	// Contents are not rendered if both files have the same checksum.
+	checksum := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	identical, err := manager.identicalNodes(context.Background(), node(SHA256(checksum)), node(SHA256(checksum)))
+	require.NoError(t, err)
+	assert.True(t, identical)
+
+	_, err = manager.identicalNodes(context.Background(), node(nil), node(nil))
+	assert.ErrorContains(t, err, "content not available")
+
+	identical, err = manager.identicalNodes(context.Background(),
+		&resourceNode{resource: &File{Path: "/foo", Content: FileContentLiteral("foo")}},
+		&resourceNode{resource: &File{Path: "/foo", Content: FileContentLiteral("bar")}},
+	)
+	require.NoError(t, err)
+	assert.False(t, identical)
+}
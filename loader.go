@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ResourceFactory returns a new resource of some type, where definitions of
+// resources of this type are decoded.
+type ResourceFactory func() Resource
+
+// Registry maps type names to resource types, so collections of resources can
+// be loaded from declarative definitions in YAML or JSON documents, like this one:
+//
+//	resources:
+//	  - type: file
+//	    path: /etc/app/config.yml
+//	    mode: 0600
+//	    content: |
+//	      port: 8080
+//
+// Fields of resources are decoded according to their yaml tags. Unknown fields
+// are reported as errors.
+type Registry struct {
+	factories map[string]ResourceFactory
+}
+
+// NewRegistry returns a registry with the resource types included in this package,
+// registered as "file" and "symlink".
+func NewRegistry() *Registry {
+	r := &Registry{
+		factories: make(map[string]ResourceFactory),
+	}
+	r.Register("file", func() Resource { return &File{} })
+	r.Register("symlink", func() Resource { return &Symlink{} })
+	return r
+}
+
+// Register registers a resource type with the given name. The factory must return
+// pointers to structs. Types registered with the same name are replaced.
+func (r *Registry) Register(name string, factory ResourceFactory) {
+	r.factories[name] = factory
+}
+
+// LoadFile loads the resources defined in a YAML or JSON file.
+func (r *Registry) LoadFile(path string) (Resources, error) {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := r.Load(bytes.NewReader(d))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return resources, nil
+}
+
+// Load loads the resources defined in a YAML or JSON document. Errors include
+// the line where they are found.
+func (r *Registry) Load(reader io.Reader) (Resources, error) {
+	var document struct {
+		Resources []yaml.Node `yaml:"resources"`
+	}
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	err := decoder.Decode(&document)
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resources Resources
+	var errs []error
+	for i := range document.Resources {
+		resource, err := r.decode(&document.Resources[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resources, nil
+}
+
+// decode decodes a resource from its definition.
+func (r *Registry) decode(node *yaml.Node) (Resource, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: resource definition must be a mapping", node.Line)
+	}
+
+	var typeName string
+	var fields yaml.Node = *node
+	fields.Content = nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "type" {
+			typeName = value.Value
+			continue
+		}
+		fields.Content = append(fields.Content, key, value)
+	}
+	if typeName == "" {
+		return nil, fmt.Errorf("line %d: resource type not specified", node.Line)
+	}
+	factory, found := r.factories[typeName]
+	if !found {
+		return nil, fmt.Errorf("line %d: unknown resource type %q", node.Line, typeName)
+	}
+
+	resource := factory()
+	if err := checkKnownFields(&fields, reflect.TypeOf(resource), typeName); err != nil {
+		return nil, err
+	}
+	if err := fields.Decode(resource); err != nil {
+		return nil, fmt.Errorf("failed to decode %s resource: %w", typeName, err)
+	}
+	return resource, nil
+}
+
+// checkKnownFields returns an error if the mapping node contains fields that are
+// not defined in the given struct type. Types that implement their own decoding
+// are not checked.
+func checkKnownFields(node *yaml.Node, t reflect.Type, typeName string) error {
+	if t.Implements(reflect.TypeFor[yaml.Unmarshaler]()) {
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	known := yamlFieldNames(t)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !slices.Contains(known, key.Value) {
+			return fmt.Errorf("line %d: unknown field %q in %s resource", key.Line, key.Value, typeName)
+		}
+	}
+	return nil
+}
+
+// yamlFieldNames returns the names of the fields of a struct type when decoded
+// from YAML.
+func yamlFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if slices.Contains(strings.Split(options, ","), "inline") {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				names = append(names, yamlFieldNames(fieldType)...)
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryLoadFile(t *testing.T) {
+	resources, err := NewRegistry().LoadFile("testdata/resources/scenario.yml")
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	results, err := manager.Apply(resources)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	d, err := os.ReadFile(filepath.Join(provider.Prefix, "app.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "port: 8080\n", string(d))
+
+	info, err := os.Stat(filepath.Join(provider.Prefix, "config", "app.yml"))
+	require.NoError(t, err)
+	assertEqualFileMode(t, fs.FileMode(0600), info.Mode())
+}
+
+func TestRegistryLoadJSON(t *testing.T) {
+	document := `{
+  "resources": [
+    {"type": "file", "path": "/foo.txt", "content": "foo", "checksum": "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}
+  ]
+}`
+	resources, err := NewRegistry().Load(strings.NewReader(document))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+
+	file, ok := resources[0].(*File)
+	require.True(t, ok)
+	assert.Equal(t, "/foo.txt", file.Path)
+	assert.Equal(t, SHA256("2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"), file.Checksum)
+
+	var sb strings.Builder
+	err = file.Content(context.Background(), NewManager(), &sb)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", sb.String())
+}
+
+func TestRegistryCustomType(t *testing.T) {
+	type customResource struct {
+		dummyResource
+		Name  string `yaml:"name"`
+		Count int
+	}
+
+	registry := NewRegistry()
+	registry.Register("custom", func() Resource { return &customResource{} })
+
+	resources, err := registry.Load(strings.NewReader(`
+resources:
+  - type: custom
+    name: foo
+    count: 3
+`))
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, &customResource{Name: "foo", Count: 3}, resources[0])
+}
+
+func TestRegistryLoadErrors(t *testing.T) {
+	cases := []struct {
+		title    string
+		document string
+		expected string
+	}{
+		{
+			title:    "empty document",
+			document: "",
+		},
+		{
+			title: "unknown type",
+			document: `resources:
+  - type: file
+    path: /foo
+  - type: package
+    name: foo
+`,
+			expected: `line 4: unknown resource type "package"`,
+		},
+		{
+			title: "missing type",
+			document: `resources:
+  - path: /foo
+`,
+			expected: "line 2: resource type not specified",
+		},
+		{
+			title: "unknown field",
+			document: `resources:
+  - type: file
+    path: /foo
+    contents: foo
+`,
+			expected: `line 4: unknown field "contents" in file resource`,
+		},
+		{
+			title: "invalid value",
+			document: `resources:
+  - type: file
+    path: /foo
+    absent: maybe
+`,
+			expected: "line 4: cannot unmarshal",
+		},
+		{
+			title: "invalid checksum",
+			document: `resources:
+  - type: file
+    path: /foo
+    checksum: foo
+`,
+			expected: "line 4: invalid checksum",
+		},
+		{
+			title: "unknown top level field",
+			document: `resource:
+  - type: file
+`,
+			expected: "line 1: field resource not found",
+		},
+		{
+			title: "not a mapping",
+			document: `resources:
+  - file
+`,
+			expected: "line 2: resource definition must be a mapping",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			resources, err := NewRegistry().Load(strings.NewReader(c.document))
+			if c.expected == "" {
+				assert.NoError(t, err)
+				assert.Empty(t, resources)
+				return
+			}
+			assert.ErrorContains(t, err, c.expected)
+			assert.Nil(t, resources)
+		})
+	}
+}
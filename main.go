@@ -18,7 +18,37 @@
 package resource
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+)
+
+// Exit codes returned by Main.RunArgs.
+const (
+	// ExitNoChanges is returned when the resources were applied without errors
+	// and no change was needed.
+	ExitNoChanges = 0
+
+	// ExitFailed is returned when the arguments are not valid, or any resource
+	// failed to be applied.
+	ExitFailed = 1
+
+	// ExitChanged is returned when the resources were applied without errors,
+	// and some of them were created or updated. In dry-run mode, it is returned
+	// if some resource would be created or updated.
+	ExitChanged = 2
+)
+
+// Output formats supported by Main.RunArgs.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
 )
 
 // Main is a helper to generate single binaries to manage a collection of resources.
@@ -31,9 +61,145 @@ type Main struct {
 
 	// Resources is the list of resources managed by this command.
 	Resources Resources
+
+	// Output is where RunArgs writes its output, defaults to the standard output.
+	Output io.Writer
+
+	// ErrorOutput is where RunArgs writes errors and usage help, defaults to the
+	// standard error.
+	ErrorOutput io.Writer
 }
 
 func (c *Main) Run() error {
+	manager := c.manager()
+
+	results, err := manager.Apply(c.Resources)
+	for _, result := range results {
+		log.Println(result)
+	}
+	return err
+}
+
+// RunArgs applies the resources with the options given in the command line
+// arguments, and returns the exit code. Arguments don't include the name of the
+// program, so it can be called as `os.Exit(main.RunArgs(os.Args[1:]))`.
+// If the first argument is "facts", the known facts are listed instead, with
+// their sources and the values they shadow, it fails if any facter reports an
+// error.
+//
+// Supported flags are:
+//
+//	--dry-run          Show the actions needed without applying them.
+//	--output=FORMAT    Output format, "text" or "json", defaults to "text".
+//	--fact KEY=VALUE   Override the value of a fact, it can be repeated.
+//	--only PATTERN     Apply only resources whose identifier or module contain
+//	                   the pattern, it can be repeated.
+//	--exclude PATTERN  Skip resources whose identifier or module contain the
+//	                   pattern, it can be repeated.
+//	--verbose          Include differences and excluded resources in the output,
+//	                   and unchanged resources with --dry-run.
+//
+// The facts subcommand supports the --output and --fact flags.
+func (c *Main) RunArgs(args []string) int {
+	out, errOut := c.Output, c.ErrorOutput
+	if out == nil {
+		out = os.Stdout
+	}
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+
+	var options mainOptions
+	if len(args) > 0 && args[0] == "facts" {
+		if exitCode, ok := options.parse(options.factsFlagSet(errOut), args[1:], errOut); !ok {
+			return exitCode
+		}
+		return c.printFacts(out, errOut, &options)
+	}
+	if exitCode, ok := options.parse(options.flagSet(errOut), args, errOut); !ok {
+		return exitCode
+	}
+
+	manager := c.manager()
+	if len(options.facts) > 0 {
+		manager.AddFacter(options.facts)
+	}
+	if len(options.only) > 0 || len(options.exclude) > 0 {
+		manager.SetFilter(options.filter)
+	}
+	manager.SetDiffs(options.verbose)
+
+	ctx := context.Background()
+	plan, err := manager.Plan(ctx, c.Resources)
+	if options.dryRun || err != nil {
+		if err := options.print(out, plan); err != nil {
+			fmt.Fprintf(errOut, "failed to write output: %v\n", err)
+			return ExitFailed
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(errOut, "error: %v\n", err)
+			return ExitFailed
+		case plan.Changes():
+			return ExitChanged
+		default:
+			return ExitNoChanges
+		}
+	}
+
+	results, err := manager.ApplyPlan(ctx, plan)
+	if err := options.print(out, results); err != nil {
+		fmt.Fprintf(errOut, "failed to write output: %v\n", err)
+		return ExitFailed
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return ExitFailed
+	case results.Changes():
+		return ExitChanged
+	default:
+		return ExitNoChanges
+	}
+}
+
+// printFacts prints the facts known by the facters, with the values they shadow,
+// and the errors reported by the facters.
+func (c *Main) printFacts(out, errOut io.Writer, options *mainOptions) int {
+	manager := c.manager()
+	if len(options.facts) > 0 {
+		manager.AddFacter(options.facts)
+	}
+
+	facts := manager.Facts()
+	if options.output == OutputJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if facts == nil {
+			facts = []FactInfo{}
+		}
+		if err := encoder.Encode(facts); err != nil {
+			fmt.Fprintf(errOut, "failed to write output: %v\n", err)
+			return ExitFailed
+		}
+	} else {
+		for _, fact := range facts {
+			fmt.Fprintf(out, "%s = %v (%s)\n", fact.Name, fact.Value, fact.Source)
+			for _, shadowed := range fact.Shadowed {
+				fmt.Fprintf(out, "    shadows %v (%s)\n", shadowed.Value, shadowed.Source)
+			}
+		}
+	}
+
+	// Facts of facters that failed are missing, report why.
+	if err := manager.factersErr(); err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return ExitFailed
+	}
+	return ExitNoChanges
+}
+
+func (c *Main) manager() *Manager {
 	manager := NewManager()
 
 	for name, provider := range c.Providers {
@@ -44,9 +210,143 @@ func (c *Main) Run() error {
 		manager.AddFacter(facter)
 	}
 
-	results, err := manager.Apply(c.Resources)
-	for _, result := range results {
-		log.Println(result)
+	return manager
+}
+
+// mainOptions are the options obtained from command line flags.
+type mainOptions struct {
+	dryRun  bool
+	output  string
+	facts   flagFacter
+	only    stringsFlag
+	exclude stringsFlag
+	verbose bool
+}
+
+func (o *mainOptions) flagSet(out io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.BoolVar(&o.dryRun, "dry-run", false, "Show the actions needed without applying them.")
+	o.addFactsFlags(flags)
+	flags.Var(&o.only, "only", "Apply only resources whose identifier or module contain the `PATTERN`. It can be repeated.")
+	flags.Var(&o.exclude, "exclude", "Skip resources whose identifier or module contain the `PATTERN`. It can be repeated.")
+	flags.BoolVar(&o.verbose, "verbose", false, "Include differences and excluded resources in the output, and unchanged resources with --dry-run.")
+	return flags
+}
+
+func (o *mainOptions) factsFlagSet(out io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(os.Args[0]+" facts", flag.ContinueOnError)
+	flags.SetOutput(out)
+	o.addFactsFlags(flags)
+	return flags
+}
+
+// addFactsFlags adds the flags used both to apply resources and to list facts.
+func (o *mainOptions) addFactsFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.output, "output", OutputText, `Output format, "text" or "json".`)
+	flags.Func("fact", "Override the value of a fact, as `KEY=VALUE`. It can be repeated.", func(value string) error {
+		name, value, found := strings.Cut(value, "=")
+		if !found || name == "" {
+			return errors.New("facts must be defined as KEY=VALUE")
+		}
+		if o.facts == nil {
+			o.facts = make(flagFacter)
+		}
+		o.facts[name] = value
+		return nil
+	})
+}
+
+// parse parses the arguments with the given flags, and validates the options.
+// It returns false if the execution should finish, with the given exit code.
+func (o *mainOptions) parse(flags *flag.FlagSet, args []string, errOut io.Writer) (int, bool) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitNoChanges, false
 	}
-	return err
+	if err != nil {
+		return ExitFailed, false
+	}
+	if o.output != OutputText && o.output != OutputJSON {
+		fmt.Fprintf(errOut, "unknown output format %q\n", o.output)
+		return ExitFailed, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(errOut, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return ExitFailed, false
+	}
+	return 0, true
+}
+
+// filter selects the resources matching the only and exclude patterns.
+func (o *mainOptions) filter(resource Resource, module string) bool {
+	matches := func(patterns []string) bool {
+		id := ResourceID(resource)
+		for _, pattern := range patterns {
+			if strings.Contains(id, pattern) || strings.Contains(module, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(o.only) > 0 && !matches(o.only) {
+		return false
+	}
+	return !matches(o.exclude)
+}
+
+// print writes a plan or a collection of results in the selected format.
+func (o *mainOptions) print(out io.Writer, report any) error {
+	if o.output == OutputJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	switch report := report.(type) {
+	case Plan:
+		for _, action := range report {
+			if !o.verbose && (action.action == ActionNone || action.reason == reasonFiltered) {
+				continue
+			}
+			fmt.Fprintln(out, action)
+			fmt.Fprint(out, action.diff)
+		}
+	case ApplyResults:
+		for _, result := range report {
+			if !o.verbose && result.reason == reasonFiltered {
+				continue
+			}
+			fmt.Fprintln(out, result)
+			fmt.Fprint(out, result.diff)
+		}
+	}
+	return nil
+}
+
+// flagFacter is a facter with the facts defined in command line flags.
+type flagFacter map[string]string
+
+func (f flagFacter) Fact(name string) (string, bool) {
+	return StaticFacter(f).Fact(name)
+}
+
+func (f flagFacter) FactNames() []string {
+	return StaticFacter(f).FactNames()
+}
+
+func (f flagFacter) String() string {
+	return "[--fact flags]"
+}
+
+// stringsFlag is a flag that can be repeated to obtain a list of values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
 }
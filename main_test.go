@@ -18,11 +18,18 @@
 package resource
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(t *testing.T) {
@@ -51,3 +58,185 @@ func TestMain(t *testing.T) {
 	_, err = os.Stat(filepath.Join(prefix, fileName))
 	assert.NoError(t, err)
 }
+
+// factContent is a file content with the value of a fact.
+func factContent(name string) FileContent {
+	return func(_ context.Context, scope Scope, w io.Writer) error {
+		value, _ := scope.Fact(name)
+		_, err := fmt.Fprint(w, value)
+		return err
+	}
+}
+
+func newTestMain(t *testing.T) (*Main, string) {
+	prefix := t.TempDir()
+	return &Main{
+		Facters: []Facter{
+			StaticFacter{"name": "default"},
+		},
+		Providers: map[string]Provider{
+			"file": &FileProvider{
+				Prefix: prefix,
+			},
+		},
+		Resources: []Resource{
+			&File{
+				Path:    "first.txt",
+				Content: factContent("name"),
+			},
+			&File{
+				Path: "second.txt",
+			},
+		},
+		Output:      &bytes.Buffer{},
+		ErrorOutput: &bytes.Buffer{},
+	}, prefix
+}
+
+func TestMainRunArgs(t *testing.T) {
+	cmd, prefix := newTestMain(t)
+
+	exitCode := cmd.RunArgs(nil)
+	assert.Equal(t, ExitChanged, exitCode)
+	assert.Equal(t, "{create: [File::first.txt]}\n{create: [File::second.txt]}\n", cmd.Output.(*bytes.Buffer).String())
+
+	d, err := os.ReadFile(filepath.Join(prefix, "first.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "default", string(d))
+
+	cmd.Output.(*bytes.Buffer).Reset()
+	exitCode = cmd.RunArgs(nil)
+	assert.Equal(t, ExitNoChanges, exitCode)
+	assert.Empty(t, cmd.Output.(*bytes.Buffer).String())
+}
+
+func TestMainRunArgsDryRun(t *testing.T) {
+	cmd, prefix := newTestMain(t)
+
+	exitCode := cmd.RunArgs([]string{"--dry-run"})
+	assert.Equal(t, ExitChanged, exitCode)
+	assert.Contains(t, cmd.Output.(*bytes.Buffer).String(), "{create: [File::first.txt], reason: resource not found}")
+
+	_, err := os.Stat(filepath.Join(prefix, "first.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	exitCode = cmd.RunArgs([]string{"--only", "second"})
+	assert.Equal(t, ExitChanged, exitCode)
+
+	// Unchanged resources are only reported in verbose dry runs.
+	cmd.Output.(*bytes.Buffer).Reset()
+	exitCode = cmd.RunArgs([]string{"--dry-run", "--verbose"})
+	assert.Equal(t, ExitChanged, exitCode)
+	assert.Contains(t, cmd.Output.(*bytes.Buffer).String(), "{none: [File::second.txt], reason: resource up to date}")
+
+	cmd.Output.(*bytes.Buffer).Reset()
+	exitCode = cmd.RunArgs([]string{"--verbose"})
+	assert.Equal(t, ExitChanged, exitCode)
+	assert.Equal(t, "{create: [File::first.txt]}\n", cmd.Output.(*bytes.Buffer).String())
+}
+
+func TestMainRunArgsFacts(t *testing.T) {
+	cmd, prefix := newTestMain(t)
+
+	exitCode := cmd.RunArgs([]string{"--fact", "name=overridden"})
+	assert.Equal(t, ExitChanged, exitCode)
+
+	d, err := os.ReadFile(filepath.Join(prefix, "first.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "overridden", string(d))
+
+	exitCode = cmd.RunArgs([]string{"--fact", "name"})
+	assert.Equal(t, ExitFailed, exitCode)
+	assert.Contains(t, cmd.ErrorOutput.(*bytes.Buffer).String(), "facts must be defined as KEY=VALUE")
+}
+
+func TestMainRunArgsFilters(t *testing.T) {
+	cmd, prefix := newTestMain(t)
+
+	exitCode := cmd.RunArgs([]string{"--only", "second"})
+	assert.Equal(t, ExitChanged, exitCode)
+	assert.Equal(t, "{create: [File::second.txt]}\n", cmd.Output.(*bytes.Buffer).String())
+	assert.NoFileExists(t, filepath.Join(prefix, "first.txt"))
+	assert.FileExists(t, filepath.Join(prefix, "second.txt"))
+
+	cmd.Output.(*bytes.Buffer).Reset()
+	exitCode = cmd.RunArgs([]string{"--exclude", "first", "--verbose"})
+	assert.Equal(t, ExitNoChanges, exitCode)
+	assert.Equal(t, "{skip: [File::first.txt], reason: excluded by filter}\n", cmd.Output.(*bytes.Buffer).String())
+	assert.NoFileExists(t, filepath.Join(prefix, "first.txt"))
+}
+
+func TestMainRunArgsJSON(t *testing.T) {
+	cmd, _ := newTestMain(t)
+
+	exitCode := cmd.RunArgs([]string{"--output=json", "--dry-run"})
+	assert.Equal(t, ExitChanged, exitCode)
+
+	var plan struct {
+		Summary map[string]int
+		Actions []map[string]string
+	}
+	err := json.Unmarshal(cmd.Output.(*bytes.Buffer).Bytes(), &plan)
+	require.NoError(t, err)
+	assert.Equal(t, 2, plan.Summary["create"])
+	require.Len(t, plan.Actions, 2)
+	assert.Equal(t, "[File::first.txt]", plan.Actions[0]["resource"])
+
+	cmd.Output.(*bytes.Buffer).Reset()
+	exitCode = cmd.RunArgs([]string{"--output=json"})
+	assert.Equal(t, ExitChanged, exitCode)
+
+	var results struct {
+		Summary map[string]int
+		Results []map[string]any
+	}
+	err = json.Unmarshal(cmd.Output.(*bytes.Buffer).Bytes(), &results)
+	require.NoError(t, err)
+	assert.Equal(t, 2, results.Summary["created"])
+	assert.Len(t, results.Results, 2)
+}
+
+func TestMainRunArgsFailed(t *testing.T) {
+	cmd, _ := newTestMain(t)
+	cmd.Resources = append(cmd.Resources, &dummyResource{absent: true, createError: errors.New("cannot create")})
+
+	exitCode := cmd.RunArgs(nil)
+	assert.Equal(t, ExitFailed, exitCode)
+	assert.Contains(t, cmd.Output.(*bytes.Buffer).String(), "failed: cannot create")
+	assert.Contains(t, cmd.ErrorOutput.(*bytes.Buffer).String(), "error: ")
+
+	exitCode = cmd.RunArgs([]string{"--output=yaml"})
+	assert.Equal(t, ExitFailed, exitCode)
+	assert.Contains(t, cmd.ErrorOutput.(*bytes.Buffer).String(), `unknown output format "yaml"`)
+}
+
+func TestMainFactsCommand(t *testing.T) {
+	cmd, _ := newTestMain(t)
+
+	exitCode := cmd.RunArgs([]string{"facts", "--fact", "name=overridden", "--fact", "other=value"})
+	assert.Equal(t, ExitNoChanges, exitCode)
+	expected := "name = overridden ([--fact flags])\n" +
+		"    shadows default (resource.StaticFacter)\n" +
+		"other = value ([--fact flags])\n"
+	assert.Equal(t, expected, cmd.Output.(*bytes.Buffer).String())
+
+	cmd.Output.(*bytes.Buffer).Reset()
+	exitCode = cmd.RunArgs([]string{"facts", "--output=json"})
+	assert.Equal(t, ExitNoChanges, exitCode)
+	assert.JSONEq(t, `[{"name": "name", "value": "default", "source": "resource.StaticFacter"}]`, cmd.Output.(*bytes.Buffer).String())
+
+	exitCode = cmd.RunArgs([]string{"facts", "--dry-run"})
+	assert.Equal(t, ExitFailed, exitCode)
+}
+
+func TestMainFactsCommandFacterError(t *testing.T) {
+	cmd, _ := newTestMain(t)
+	path := filepath.Join(t.TempDir(), "facts.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"env": `), 0644))
+	cmd.Facters = append(cmd.Facters, &FileFacter{Path: path})
+
+	exitCode := cmd.RunArgs([]string{"facts"})
+	assert.Equal(t, ExitFailed, exitCode)
+	assert.Equal(t, "name = default (resource.StaticFacter)\n", cmd.Output.(*bytes.Buffer).String())
+	assert.Contains(t, cmd.ErrorOutput.(*bytes.Buffer).String(), "error: facter [FileFacter:"+path+"] failed: failed to parse facts file")
+}
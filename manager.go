@@ -19,8 +19,14 @@ package resource
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
+	"strings"
+	"time"
 )
 
 // Provider is the interface implemented by providers.
@@ -36,6 +42,37 @@ type Facter interface {
 	Fact(name string) (value string, found bool)
 }
 
+// TypedFacter is implemented by facters that can provide facts with structured
+// values. Values can be strings, booleans, numbers, lists of values ([]any) or
+// maps of values (map[string]any). Fact is still used to obtain the values
+// as strings.
+type TypedFacter interface {
+	Facter
+
+	// FactValue returns the value of a fact for a given name and true if it is found.
+	// It not found, it returns nil and false.
+	FactValue(name string) (value any, found bool)
+}
+
+// FactLister is implemented by facters that can list the facts they know. Only
+// facts of facters implementing it are included when listing facts.
+type FactLister interface {
+	Facter
+
+	// FactNames returns the names of the facts known by the facter.
+	FactNames() []string
+}
+
+// FallibleFacter is implemented by facters that can fail to obtain their facts,
+// as the ones reading them from files. Plans fail if any facter reports an error,
+// and errors are included when facts are not found.
+type FallibleFacter interface {
+	Facter
+
+	// Err returns the error that prevents obtaining the facts, if any.
+	Err() error
+}
+
 // StaticFacter is a facter implemented as map.
 type StaticFacter map[string]string
 
@@ -49,6 +86,40 @@ func (f StaticFacter) Fact(name string) (value string, found bool) {
 	return
 }
 
+// FactNames returns the names of the facts in the map.
+func (f StaticFacter) FactNames() []string {
+	return slices.Collect(maps.Keys(f))
+}
+
+// StaticValuesFacter is a typed facter implemented as map. Values of facts are
+// formatted as strings when obtained with Fact.
+type StaticValuesFacter map[string]any
+
+// Fact returns the value of a fact for a given name formatted as string, and true
+// if it is found. It not found, it returns an empty string and false.
+func (f StaticValuesFacter) Fact(name string) (string, bool) {
+	value, found := f[name]
+	if !found {
+		return "", false
+	}
+	if value == nil {
+		return "", true
+	}
+	return fmt.Sprint(value), true
+}
+
+// FactValue returns the value of a fact for a given name and true if it is found.
+// It not found, it returns nil and false.
+func (f StaticValuesFacter) FactValue(name string) (any, bool) {
+	value, found := f[name]
+	return value, found
+}
+
+// FactNames returns the names of the facts in the map.
+func (f StaticValuesFacter) FactNames() []string {
+	return slices.Collect(maps.Keys(f))
+}
+
 // Resource implements management for a resource.
 type Resource interface {
 	// Get gets the current state of a resource. An error is returned if the state couldn't
@@ -74,9 +145,64 @@ type ResourceState interface {
 	NeedsUpdate(ctx context.Context, definition Resource) (bool, error)
 }
 
+// DiffableState is implemented by states of resources that can describe their
+// differences with the definition of the resource.
+type DiffableState interface {
+	ResourceState
+
+	// Diff returns a description of the differences between the current state
+	// and the given resource definition. Unified diff format is used when possible.
+	// It is called after NeedsUpdate, and only if an update is needed.
+	Diff(ctx context.Context, definition Resource) (string, error)
+}
+
 // Resources is a collection of resources.
 type Resources []Resource
 
+// unwrapResource returns the resource wrapped by the given one, or nil if it
+// doesn't wrap any resource. Wrappers implement `Unwrap() Resource`.
+func unwrapResource(resource Resource) Resource {
+	wrapper, ok := resource.(interface{ Unwrap() Resource })
+	if !ok {
+		return nil
+	}
+	return wrapper.Unwrap()
+}
+
+// asResource finds the first resource in the chain of wrapped resources that
+// implements T, and returns it.
+func asResource[T any](resource Resource) (T, bool) {
+	for resource != nil {
+		if found, ok := resource.(T); ok {
+			return found, true
+		}
+		resource = unwrapResource(resource)
+	}
+	var zero T
+	return zero, false
+}
+
+// wrappedResourceState is the state of a wrapped resource. It is returned by
+// resource wrappers so the state is compared with the wrapped definition.
+type wrappedResourceState struct {
+	ResourceState
+	resource Resource
+}
+
+// NeedsUpdate returns true if the wrapped resource needs update.
+func (s *wrappedResourceState) NeedsUpdate(ctx context.Context, _ Resource) (bool, error) {
+	return s.ResourceState.NeedsUpdate(ctx, s.resource)
+}
+
+// Diff returns the differences of the wrapped resource, if its state supports it.
+func (s *wrappedResourceState) Diff(ctx context.Context, _ Resource) (string, error) {
+	differ, ok := s.ResourceState.(DiffableState)
+	if !ok {
+		return "", nil
+	}
+	return differ.Diff(ctx, s.resource)
+}
+
 // Actions reported on results when applying resources.
 const (
 	// ActionUnknown is used to indicate a failure happening before determining the required action.
@@ -87,13 +213,188 @@ const (
 
 	// ActionUpdate refers to an action that affects an existing resource.
 	ActionUpdate = "update"
+
+	// ActionNone is used in plans to indicate that a resource doesn't need any change.
+	ActionNone = "none"
+
+	// ActionSkip refers to a resource that was not applied, the reason is included
+	// in the result.
+	ActionSkip = "skip"
+
+	// ActionRun refers to a handler that was run because it was notified by a
+	// resource that changed.
+	ActionRun = "run"
+
+	// ActionRevert refers to a resource whose changes were reverted after a
+	// failure in transactional mode.
+	ActionRevert = "revert"
+)
+
+// Reasons reported on plans for the planned actions.
+const (
+	reasonNotFound    = "resource not found"
+	reasonNeedsUpdate = "resource differs from definition"
+	reasonUpToDate    = "resource up to date"
+	reasonDisabled    = "condition not met"
+	reasonFiltered    = "excluded by filter"
 )
 
+// PlannedAction is the action planned for a resource, before applying it.
+type PlannedAction struct {
+	action   string
+	reason   string
+	diff     string
+	resource Resource
+	err      error
+
+	node *resourceNode
+}
+
+// Action returns the action planned for the resource.
+func (a PlannedAction) Action() string {
+	return a.action
+}
+
+// Reason returns a description of why the action was planned.
+func (a PlannedAction) Reason() string {
+	return a.reason
+}
+
+// Resource returns the resource this action was planned for.
+func (a PlannedAction) Resource() Resource {
+	return a.resource
+}
+
+// Module returns the path of the module where the resource is defined, or an
+// empty string if it is not defined in a module.
+func (a PlannedAction) Module() string {
+	if a.node == nil {
+		return ""
+	}
+	return a.node.module
+}
+
+// Diff returns the differences between the current state of the resource and
+// its definition, if the resource needs update and supports it. Diffs are only
+// obtained if they are enabled in the manager.
+func (a PlannedAction) Diff() string {
+	return a.diff
+}
+
+// Err returns an error if the action for the resource couldn't be determined.
+func (a PlannedAction) Err() error {
+	return a.err
+}
+
+// String returns the string representation of the planned action.
+func (a PlannedAction) String() string {
+	return formatResult(a.action, a.resource, a.Module(), a.reason, a.err)
+}
+
+// Plan is the collection of actions planned to apply a collection of resources.
+// It can be inspected before applying it with the manager.
+type Plan []PlannedAction
+
+// Changes returns true if applying the plan would create or update any resource.
+func (p Plan) Changes() bool {
+	for _, action := range p {
+		if action.action == ActionCreate || action.action == ActionUpdate {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON returns the JSON representation of the planned action. Resources
+// are identified by their ResourceID.
+func (a PlannedAction) MarshalJSON() ([]byte, error) {
+	action := jsonPlannedAction{
+		Resource: ResourceID(a.resource),
+		Module:   a.Module(),
+		Action:   a.action,
+		Reason:   a.reason,
+		Diff:     a.diff,
+	}
+	if a.err != nil {
+		action.Error = a.err.Error()
+	}
+	return json.Marshal(action)
+}
+
+// jsonPlannedAction is the JSON representation of a planned action.
+type jsonPlannedAction struct {
+	Resource string `json:"resource"`
+	Module   string `json:"module,omitempty"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Diff     string `json:"diff,omitempty"`
+}
+
+// MarshalJSON returns the JSON representation of the plan, with a summary of
+// the number of resources for each action.
+func (p Plan) MarshalJSON() ([]byte, error) {
+	summary := struct {
+		Create int `json:"create"`
+		Update int `json:"update"`
+		Skip   int `json:"skip"`
+		Failed int `json:"failed"`
+	}{}
+	for _, action := range p {
+		switch {
+		case action.err != nil:
+			summary.Failed++
+		case action.action == ActionCreate:
+			summary.Create++
+		case action.action == ActionUpdate:
+			summary.Update++
+		case action.action == ActionSkip:
+			summary.Skip++
+		}
+	}
+
+	// Use a plain slice to avoid calling this method recursively.
+	actions := []PlannedAction(p)
+	if actions == nil {
+		actions = []PlannedAction{}
+	}
+	return json.Marshal(struct {
+		Summary any             `json:"summary"`
+		Actions []PlannedAction `json:"actions"`
+	}{
+		Summary: summary,
+		Actions: actions,
+	})
+}
+
 // ApplyResult is the result of applying a resource.
 type ApplyResult struct {
 	action   string
 	resource Resource
+	module   string
+	reason   string
+	diff     string
+	backups  []string
 	err      error
+
+	started  time.Time
+	duration time.Duration
+}
+
+// Action returns the action applied to the resource.
+func (r ApplyResult) Action() string {
+	return r.action
+}
+
+// Resource returns the resource this result refers to.
+func (r ApplyResult) Resource() Resource {
+	return r.resource
+}
+
+// Reason returns a description of why the resource was not applied, if it
+// was skipped.
+func (r ApplyResult) Reason() string {
+	return r.reason
 }
 
 // Err returns an error if the application of a resource failed.
@@ -101,18 +402,160 @@ func (r ApplyResult) Err() error {
 	return r.err
 }
 
+// Module returns the path of the module where the resource is defined, or an
+// empty string if it is not defined in a module.
+func (r ApplyResult) Module() string {
+	return r.module
+}
+
+// Diff returns the differences between the previous state of the resource and
+// its definition, if the resource was updated and it supports it. Diffs are only
+// obtained if they are enabled in the manager.
+func (r ApplyResult) Diff() string {
+	return r.diff
+}
+
+// Backups returns the paths of the backups made while applying the resource.
+func (r ApplyResult) Backups() []string {
+	return r.backups
+}
+
+// StartedAt returns the time when the application of the resource started.
+func (r ApplyResult) StartedAt() time.Time {
+	return r.started
+}
+
+// Duration returns the time it took to apply the resource.
+func (r ApplyResult) Duration() time.Duration {
+	return r.duration
+}
+
 // String returns the string representation of the result of applying a resource.
 func (r ApplyResult) String() string {
+	return formatResult(r.action, r.resource, r.module, r.reason, r.err)
+}
+
+// formatResult returns the string representation of results and planned actions.
+func formatResult(action string, resource Resource, module string, reason string, err error) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "{%s: %s", action, resource)
+	if module != "" {
+		fmt.Fprintf(&sb, ", module: %s", module)
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(&sb, ", failed: %v", err)
+	case reason != "":
+		fmt.Fprintf(&sb, ", reason: %s", reason)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// jsonResult is the JSON representation of a result.
+type jsonResult struct {
+	Resource string    `json:"resource"`
+	Module   string    `json:"module,omitempty"`
+	Action   string    `json:"action"`
+	Reason   string    `json:"reason,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Diff     string    `json:"diff,omitempty"`
+	Backups  []string  `json:"backups,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration_seconds"`
+}
+
+// MarshalJSON returns the JSON representation of the result. Resources are
+// identified by their ResourceID.
+func (r ApplyResult) MarshalJSON() ([]byte, error) {
+	result := jsonResult{
+		Resource: ResourceID(r.resource),
+		Module:   r.module,
+		Action:   r.action,
+		Reason:   r.reason,
+		Diff:     r.diff,
+		Backups:  r.backups,
+		Started:  r.started,
+		Duration: r.duration.Seconds(),
+	}
 	if r.err != nil {
-		return fmt.Sprintf("{%s: %s, failed: %v}", r.action, r.resource, r.err)
-	} else {
-		return fmt.Sprintf("{%s: %s}", r.action, r.resource)
+		result.Error = r.err.Error()
 	}
+	return json.Marshal(result)
 }
 
 // ApplyResults is the colection of results when applying a collection of resources.
 type ApplyResults []ApplyResult
 
+// Changes returns true if any resource was created or updated.
+func (r ApplyResults) Changes() bool {
+	for _, result := range r {
+		if result.err == nil && (result.action == ActionCreate || result.action == ActionUpdate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns true if the application of any resource failed.
+func (r ApplyResults) Failed() bool {
+	for _, result := range r {
+		if result.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON returns the JSON representation of the results, with a summary
+// of the number of resources for each action.
+func (r ApplyResults) MarshalJSON() ([]byte, error) {
+	summary := struct {
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+		Skipped int `json:"skipped"`
+		Run     int `json:"run"`
+		Failed  int `json:"failed"`
+	}{}
+	for _, result := range r {
+		switch {
+		case result.err != nil:
+			summary.Failed++
+		case result.action == ActionCreate:
+			summary.Created++
+		case result.action == ActionUpdate:
+			summary.Updated++
+		case result.action == ActionSkip:
+			summary.Skipped++
+		case result.action == ActionRun:
+			summary.Run++
+		}
+	}
+
+	// Use a plain slice to avoid calling this method recursively.
+	results := []ApplyResult(r)
+	if results == nil {
+		results = []ApplyResult{}
+	}
+	return json.Marshal(struct {
+		Summary any           `json:"summary"`
+		Results []ApplyResult `json:"results"`
+	}{
+		Summary: summary,
+		Results: results,
+	})
+}
+
+// ResourceID returns a stable identifier for a resource, that can be used to
+// refer to it in reports. It is the string representation of the resource if
+// it implements fmt.Stringer, or its type otherwise.
+func ResourceID(resource Resource) string {
+	if stringer, ok := resource.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", resource)
+}
+
 // Scope contains the information available to resources when applying them.
 type Scope interface {
 	// Provider obtains a provider and sets it in the target.
@@ -124,13 +567,35 @@ type Scope interface {
 	// Fact returns the value of a fact for a given name and true if it is found.
 	// It not found, it returns an empty string and false.
 	Fact(name string) (value string, found bool)
+
+	// FactValue returns the value of a fact for a given name and true if it is found.
+	// Values of facts obtained from typed facters keep their types, other facts
+	// are strings. It not found, it returns nil and false.
+	FactValue(name string) (value any, found bool)
+
+	// FactBool returns the value of a fact as a boolean. An error is returned if
+	// the fact is not found or it is not a boolean.
+	FactBool(name string) (bool, error)
+
+	// FactInt returns the value of a fact as an integer. An error is returned if
+	// the fact is not found or it is not an integer.
+	FactInt(name string) (int, error)
+
+	// FactList returns the value of a fact as a list of strings. String values are
+	// split by commas. An error is returned if the fact is not found or it is not
+	// a list.
+	FactList(name string) ([]string, error)
 }
 
 // Manager manages application of resources, it contains references to providers and
 // facters.
 type Manager struct {
-	providers map[string]Provider
-	facters   []Facter
+	providers     map[string]Provider
+	facters       []Facter
+	parallelism   int
+	diffs         bool
+	filter        ResourceFilter
+	transactional bool
 
 	// TBD: pending to confirm migrating API
 	migrator *Migrator
@@ -148,6 +613,41 @@ func (m *Manager) RegisterProvider(name string, provider Provider) {
 	m.providers[name] = provider
 }
 
+// SetParallelism sets the maximum number of resources that can be applied
+// concurrently. Only resources that don't depend on each other are applied
+// concurrently. Values lower than 2 disable concurrency, what is the default.
+// Results are reported in the same order in any case.
+func (m *Manager) SetParallelism(n int) {
+	m.parallelism = n
+}
+
+// SetDiffs enables or disables obtaining the differences between the current state
+// of resources that need update and their definitions. Differences are included in
+// plans and results. They are disabled by default.
+func (m *Manager) SetDiffs(enabled bool) {
+	m.diffs = enabled
+}
+
+// SetTransactional enables or disables the transactional mode. In transactional
+// mode, the state of resources is recorded before changing them, and if any
+// resource fails, the changes of the previous ones are reverted in reverse order.
+// All the resources applied must implement RevertibleResource, and they are
+// applied sequentially.
+func (m *Manager) SetTransactional(enabled bool) {
+	m.transactional = enabled
+}
+
+// ResourceFilter decides if a resource should be applied. It receives the resource
+// and the path of the module where it is defined.
+type ResourceFilter func(resource Resource, module string) bool
+
+// SetFilter sets a filter to select the resources to apply. Resources excluded
+// by the filter are skipped. The filter is evaluated after expanding modules and
+// expandable resources. Resources depending on skipped resources are still applied.
+func (m *Manager) SetFilter(filter ResourceFilter) {
+	m.filter = filter
+}
+
 // withMigrator sets a migrator in the manager.
 // TBD: not exposed, pending to confirm migrating API
 func (m *Manager) withMigrator(migrator *Migrator) {
@@ -166,6 +666,11 @@ func (m *Manager) Provider(name string, target any) bool {
 	if !found {
 		return false
 	}
+	return setProvider(p, target)
+}
+
+// setProvider sets the provider in the target if it is assignable to it.
+func setProvider(p Provider, target any) bool {
 	val := reflect.ValueOf(target)
 	if !reflect.TypeOf(p).AssignableTo(val.Elem().Type()) {
 		return false
@@ -201,75 +706,270 @@ func (m *Manager) applyMigrations() (ApplyResults, error) {
 	}
 
 	// Avoid infinite loops.
-	managerWithoutMigrator := &Manager{
-		providers: m.providers,
-		facters:   m.facters,
-	}
-	return m.migrator.RunMigrations(managerWithoutMigrator)
+	managerWithoutMigrator := *m
+	managerWithoutMigrator.migrator = nil
+	return m.migrator.RunMigrations(&managerWithoutMigrator)
 }
 
-// applyResources applies a collection of resources. Depending on their current
-// state, resources are created or updated.
-func (m *Manager) applyResources(ctx context.Context, resources Resources) (ApplyResults, error) {
-	var results ApplyResults
+// Plan obtains the current state of a collection of resources and returns the
+// actions that would be needed to apply them, without applying any change.
+// Actions are sorted so dependencies are before the resources depending on them.
+// Migrations are not considered when planning.
+// The returned plan can be applied with ApplyPlan.
+func (m *Manager) Plan(ctx context.Context, resources Resources) (Plan, error) {
+	nodes, err := m.prepareNodes(ctx, resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
 	var errors []error
-	for _, resource := range resources {
+	for _, node := range nodes {
 		if err := ctx.Err(); err != nil {
-			errors = append(errors, fmt.Errorf("apply interrupted: %w", err))
+			errors = append(errors, fmt.Errorf("plan interrupted: %w", err))
 			break
 		}
 
-		result := m.applyResource(ctx, resource)
-		if result == nil {
-			continue
+		action := m.planResource(ctx, node)
+		if action.err != nil {
+			errors = append(errors, action.err)
 		}
-		if result.err != nil {
-			errors = append(errors, result.err)
+		plan = append(plan, action)
+	}
+	return plan, newApplyError(errors)
+}
+
+// ApplyPlan applies the actions in a plan previously obtained with Plan. The
+// state of the resources is not obtained again, so the plan should be applied
+// soon after obtaining it.
+func (m *Manager) ApplyPlan(ctx context.Context, plan Plan) (ApplyResults, error) {
+	nodes := make([]*resourceNode, len(plan))
+	actions := make(map[*resourceNode]PlannedAction, len(plan))
+	for i, action := range plan {
+		if action.node == nil {
+			action.node = &resourceNode{resource: action.resource, scope: m}
+		}
+		nodes[i] = action.node
+		actions[action.node] = action
+	}
+	if err := m.validateNodes(nodes); err != nil {
+		return nil, err
+	}
+	if m.transactional {
+		if err := m.checkRevertible(nodes); err != nil {
+			return nil, err
 		}
-		results = append(results, *result)
 	}
-	return results, newApplyError(errors)
+	return m.execute(ctx, nodes, func(node *resourceNode) PlannedAction {
+		return actions[node]
+	})
 }
 
-// applyResource is a helper function that applies a single resource.
-func (m *Manager) applyResource(ctx context.Context, resource Resource) *ApplyResult {
-	current, err := resource.Get(ctx, m)
+// applyResources applies a collection of resources. Depending on their current
+// state, resources are created or updated.
+func (m *Manager) applyResources(ctx context.Context, resources Resources) (ApplyResults, error) {
+	nodes, err := m.prepareNodes(ctx, resources)
+	if err != nil {
+		return nil, err
+	}
+	return m.execute(ctx, nodes, func(node *resourceNode) PlannedAction {
+		return m.planResource(ctx, node)
+	})
+}
+
+// nodeScope returns the scope used to apply a node.
+func (m *Manager) nodeScope(node *resourceNode) Scope {
+	if node.scope == nil {
+		return m
+	}
+	return node.scope
+}
+
+// selected returns true if the resource of a node is going to be applied, this
+// is if it is not excluded by the filter, and its condition is met. Nodes that
+// already failed, or whose condition cannot be evaluated, are not selected.
+func (m *Manager) selected(node *resourceNode) bool {
+	if node.err != nil {
+		return false
+	}
+	if m.filter != nil && !m.filter(node.resource, node.module) {
+		return false
+	}
+	if conditional, ok := asResource[ConditionalResource](node.resource); ok {
+		// Errors evaluating conditions are reported when planning.
+		enabled, err := conditional.Enabled(m.nodeScope(node))
+		if err != nil || !enabled {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareNodes returns the sorted nodes to apply a collection of resources, after
+// merging duplicated resources and validating them.
+func (m *Manager) prepareNodes(ctx context.Context, resources Resources) ([]*resourceNode, error) {
+	nodes, err := resourceGraph(ctx, resources, m)
+	if err != nil {
+		return nil, newApplyError([]error{err})
+	}
+	if err := m.checkFacters(nodes); err != nil {
+		return nil, err
+	}
+	nodes, err = m.mergeDuplicates(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validateNodes(nodes); err != nil {
+		return nil, err
+	}
+	if m.transactional {
+		if err := m.checkRevertible(nodes); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// planResource is a helper function that decides the action needed to apply a
+// single resource, depending on its current state.
+func (m *Manager) planResource(ctx context.Context, node *resourceNode) PlannedAction {
+	resource := node.resource
+	if m.diffs {
+		ctx = withDiffs(ctx)
+	}
+	if m.filter != nil && !m.filter(resource, node.module) {
+		return PlannedAction{
+			action:   ActionSkip,
+			reason:   reasonFiltered,
+			resource: resource,
+			node:     node,
+		}
+	}
+
+	if conditional, ok := asResource[ConditionalResource](resource); ok {
+		enabled, err := conditional.Enabled(node.scope)
+		if err != nil {
+			return PlannedAction{
+				action:   ActionUnknown,
+				resource: resource,
+				err:      err,
+				node:     node,
+			}
+		}
+		if !enabled {
+			return PlannedAction{
+				action:   ActionSkip,
+				reason:   reasonDisabled,
+				resource: resource,
+				node:     node,
+			}
+		}
+	}
+
+	// Nodes can fail before planning, as when resources cannot be expanded. This
+	// is only an error if the resource is going to be applied.
+	if node.err != nil {
+		return PlannedAction{
+			action:   ActionUnknown,
+			resource: resource,
+			err:      node.err,
+			node:     node,
+		}
+	}
+
+	current, err := resource.Get(ctx, node.scope)
 	if err != nil {
-		return &ApplyResult{
+		return PlannedAction{
 			action:   ActionUnknown,
 			resource: resource,
 			err:      err,
+			node:     node,
 		}
 	}
 
 	if !current.Found(ctx) {
-		err := resource.Create(ctx, m)
-		return &ApplyResult{
+		return PlannedAction{
 			action:   ActionCreate,
+			reason:   reasonNotFound,
 			resource: resource,
-			err:      err,
+			node:     node,
 		}
 	}
 
 	needsUpdate, err := current.NeedsUpdate(ctx, resource)
 	if err != nil {
-		return &ApplyResult{
+		return PlannedAction{
 			action:   ActionUnknown,
 			resource: resource,
 			err:      err,
+			node:     node,
 		}
 	}
 	if needsUpdate {
-		err := resource.Update(ctx, m)
-		return &ApplyResult{
+		action := PlannedAction{
 			action:   ActionUpdate,
+			reason:   reasonNeedsUpdate,
 			resource: resource,
-			err:      err,
+			node:     node,
+		}
+		if differ, ok := current.(DiffableState); ok && m.diffs {
+			diff, err := differ.Diff(ctx, resource)
+			if err != nil {
+				diff = fmt.Sprintf("failed to obtain differences: %v\n", err)
+			}
+			action.diff = diff
 		}
+		return action
 	}
 
-	// No action applied to this resource.
-	return nil
+	return PlannedAction{
+		action:   ActionNone,
+		reason:   reasonUpToDate,
+		resource: resource,
+		node:     node,
+	}
+}
+
+// applyPlannedAction is a helper function that executes the action planned for
+// a single resource.
+func (m *Manager) applyPlannedAction(ctx context.Context, action PlannedAction) *ApplyResult {
+	result := &ApplyResult{
+		action:   action.action,
+		resource: action.resource,
+		module:   action.Module(),
+		diff:     action.diff,
+		started:  time.Now(),
+	}
+	defer func() {
+		result.duration = time.Since(result.started)
+	}()
+	if action.err != nil {
+		result.err = action.err
+		return result
+	}
+
+	scope := Scope(m)
+	if action.node != nil && action.node.scope != nil {
+		scope = action.node.scope
+	}
+
+	ctx, backups := withBackupsRecorder(ctx)
+	defer func() {
+		result.backups = backups.Paths()
+	}()
+
+	switch action.action {
+	case ActionCreate:
+		result.err = action.resource.Create(ctx, scope)
+	case ActionUpdate:
+		result.err = action.resource.Update(ctx, scope)
+	case ActionSkip:
+		result.reason = action.reason
+	default:
+		// No action applied to this resource.
+		return nil
+	}
+	return result
 }
 
 // AddFacter adds a facter to the manager. Facters added later have precedence.
@@ -291,6 +991,94 @@ func (m *Manager) Fact(name string) (string, bool) {
 	return "", false
 }
 
+// FactValue returns the value of a fact for a given name and true if it is found.
+// If a fact is available in multiple facters, the value in the last added facter
+// is returned.
+func (m *Manager) FactValue(name string) (any, bool) {
+	for _, facter := range m.facters {
+		v, found := factValue(facter, name)
+		if found {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// FactInfo describes a fact, with the facter it is obtained from, and the values
+// defined in other facters that are shadowed by it.
+type FactInfo struct {
+	Name     string         `json:"name"`
+	Value    any            `json:"value"`
+	Source   string         `json:"source"`
+	Shadowed []ShadowedFact `json:"shadowed,omitempty"`
+}
+
+// ShadowedFact is a value of a fact that is not used because another facter
+// with precedence defines it.
+type ShadowedFact struct {
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// Facts returns the facts known by the facters in the manager, sorted by name.
+// Only facters implementing FactLister are considered. Facters are identified
+// by their string representation, or by their type if they don't implement
+// fmt.Stringer.
+func (m *Manager) Facts() []FactInfo {
+	index := make(map[string]*FactInfo)
+	var names []string
+	for _, facter := range m.facters {
+		lister, ok := facter.(FactLister)
+		if !ok {
+			continue
+		}
+		source := facterName(facter)
+		for _, name := range lister.FactNames() {
+			value, found := factValue(facter, name)
+			if !found {
+				continue
+			}
+			info, found := index[name]
+			if !found {
+				index[name] = &FactInfo{Name: name, Value: value, Source: source}
+				names = append(names, name)
+				continue
+			}
+			info.Shadowed = append(info.Shadowed, ShadowedFact{Value: value, Source: source})
+		}
+	}
+
+	slices.Sort(names)
+	facts := make([]FactInfo, len(names))
+	for i, name := range names {
+		facts[i] = *index[name]
+	}
+	return facts
+}
+
+// facterName returns a name to identify a facter.
+func facterName(facter Facter) string {
+	if stringer, ok := facter.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", facter)
+}
+
+// FactBool returns the value of a fact as a boolean.
+func (m *Manager) FactBool(name string) (bool, error) {
+	return factBool(m, name)
+}
+
+// FactInt returns the value of a fact as an integer.
+func (m *Manager) FactInt(name string) (int, error) {
+	return factInt(m, name)
+}
+
+// FactList returns the value of a fact as a list of strings.
+func (m *Manager) FactList(name string) ([]string, error) {
+	return factList(m, name)
+}
+
 // applyError wraps all the errors happened while applying a set of resources.
 // Errors can be unwrapped with `Unwrap() []error`.
 type applyError struct {
@@ -318,3 +1106,60 @@ func (e *applyError) Error() string {
 func (e *applyError) Unwrap() []error {
 	return e.errors
 }
+
+// factersErr returns the errors reported by the facters of the manager.
+func (m *Manager) factersErr() error {
+	return factersErr(m.facters)
+}
+
+// checkFacters returns an error if any of the facters of the manager, or of the
+// modules of the nodes, reports an error.
+func (m *Manager) checkFacters(nodes []*resourceNode) error {
+	var errs []error
+	if err := m.factersErr(); err != nil {
+		errs = append(errs, err)
+	}
+	checked := make(map[*Module]bool)
+	for _, node := range nodes {
+		for scope, ok := node.scope.(*moduleScope); ok; scope, ok = scope.parent.(*moduleScope) {
+			if checked[scope.module] {
+				break
+			}
+			checked[scope.module] = true
+			if err := factersErr(scope.module.Facters); err != nil {
+				errs = append(errs, fmt.Errorf("module %s: %w", scope.module.Name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return newApplyError(errs)
+	}
+	return nil
+}
+
+// factersErr returns the errors reported by fallible facters.
+func factersErr(facters []Facter) error {
+	var errs []error
+	for _, facter := range facters {
+		fallible, ok := facter.(FallibleFacter)
+		if !ok {
+			continue
+		}
+		if err := fallible.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("facter %s failed: %w", facterName(facter), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// errFactNotFound returns the error for a fact not found in a scope. It includes
+// the errors reported by the facters in the scope, that could be the reason why
+// the fact is not found.
+func errFactNotFound(scope Scope, name string) error {
+	if scope, ok := scope.(interface{ factersErr() error }); ok {
+		if err := scope.factersErr(); err != nil {
+			return fmt.Errorf("fact %q not found: %w", name, err)
+		}
+	}
+	return fmt.Errorf("fact %q not found", name)
+}
@@ -19,11 +19,14 @@ package resource
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestManagerProviders(t *testing.T) {
@@ -72,6 +75,205 @@ func TestManagerContextCancelled(t *testing.T) {
 	})
 }
 
+func TestManagerPlan(t *testing.T) {
+	m := NewManager()
+	resources := Resources{
+		&dummyResource{absent: true},
+		&dummyResource{needsUpdate: true},
+		&dummyResource{},
+		&dummyResource{getError: errors.New("cannot get state")},
+	}
+
+	plan, err := m.Plan(context.Background(), resources)
+	assert.Error(t, err)
+	require.Len(t, plan, 4)
+	assert.True(t, plan.Changes())
+
+	expected := []struct {
+		action string
+		reason string
+		err    bool
+	}{
+		{ActionCreate, reasonNotFound, false},
+		{ActionUpdate, reasonNeedsUpdate, false},
+		{ActionNone, reasonUpToDate, false},
+		{ActionUnknown, "", true},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.action, plan[i].Action())
+		assert.Equal(t, e.reason, plan[i].Reason())
+		assert.Equal(t, e.err, plan[i].Err() != nil)
+		assert.Equal(t, resources[i], plan[i].Resource())
+	}
+
+	for _, r := range resources {
+		assert.Zero(t, r.(*dummyResource).created+r.(*dummyResource).updated, "plan should not apply changes")
+	}
+
+	results, err := m.ApplyPlan(context.Background(), plan)
+	assert.Error(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, ActionCreate, results[0].action)
+		assert.Equal(t, ActionUpdate, results[1].action)
+		assert.Equal(t, ActionUnknown, results[2].action)
+		assert.Error(t, results[2].Err())
+	}
+	assert.Equal(t, 1, resources[0].(*dummyResource).created)
+	assert.Equal(t, 1, resources[1].(*dummyResource).updated)
+	assert.Zero(t, resources[2].(*dummyResource).created+resources[2].(*dummyResource).updated)
+}
+
+func TestManagerPlanNoChanges(t *testing.T) {
+	m := NewManager()
+	plan, err := m.Plan(context.Background(), Resources{&dummyResource{}})
+	require.NoError(t, err)
+	assert.False(t, plan.Changes())
+
+	results, err := m.ApplyPlan(context.Background(), plan)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestManagerDependencies(t *testing.T) {
+	t.Run("dependencies applied first", func(t *testing.T) {
+		first := &dummyResource{absent: true}
+		second := &dummyResource{absent: true, dependencies: Resources{first}}
+		independent := &dummyResource{absent: true}
+
+		m := NewManager()
+		results, err := m.Apply(Resources{second, independent, first})
+		require.NoError(t, err)
+		if assert.Len(t, results, 3) {
+			assert.Equal(t, first, results[0].resource)
+			assert.Equal(t, second, results[1].resource)
+			assert.Equal(t, independent, results[2].resource)
+		}
+	})
+	t.Run("dependents skipped on failure", func(t *testing.T) {
+		failing := &dummyResource{absent: true, createError: errors.New("failed")}
+		dependent := &dummyResource{absent: true, dependencies: Resources{failing}}
+		transitive := &dummyResource{absent: true, dependencies: Resources{dependent}}
+		independent := &dummyResource{absent: true}
+
+		m := NewManager()
+		results, err := m.Apply(Resources{transitive, dependent, failing, independent})
+		assert.Error(t, err)
+		if assert.Len(t, results, 4) {
+			assert.Equal(t, ActionCreate, results[0].action)
+			assert.Error(t, results[0].Err())
+			assert.Equal(t, ActionSkip, results[1].action)
+			assert.NoError(t, results[1].Err())
+			assert.Equal(t, ActionSkip, results[2].action)
+			assert.Equal(t, ActionCreate, results[3].action)
+			assert.NoError(t, results[3].Err())
+		}
+		assert.Zero(t, dependent.created)
+		assert.Zero(t, transitive.created)
+		assert.Equal(t, 1, independent.created)
+	})
+	t.Run("dependents skipped on plan failure", func(t *testing.T) {
+		failing := &dummyResource{getError: errors.New("failed")}
+		dependent := &dummyResource{absent: true, dependencies: Resources{failing}}
+
+		m := NewManager()
+		plan, err := m.Plan(context.Background(), Resources{dependent, failing})
+		assert.Error(t, err)
+		require.Len(t, plan, 2)
+		assert.Equal(t, failing, plan[0].Resource())
+		assert.Equal(t, ActionCreate, plan[1].Action())
+
+		results, err := m.ApplyPlan(context.Background(), plan)
+		assert.Error(t, err)
+		if assert.Len(t, results, 2) {
+			assert.Equal(t, ActionUnknown, results[0].action)
+			assert.Equal(t, ActionSkip, results[1].action)
+		}
+		assert.Zero(t, dependent.created)
+	})
+	t.Run("cycles are not applied", func(t *testing.T) {
+		a := &dummyResource{absent: true}
+		b := &dummyResource{absent: true, dependencies: Resources{a}}
+		a.dependencies = Resources{b}
+
+		m := NewManager()
+		results, err := m.Apply(Resources{a, b})
+		assert.Error(t, err)
+		assert.Empty(t, results)
+		assert.Zero(t, a.created+b.created)
+	})
+}
+
+func TestApplyResults(t *testing.T) {
+	m := NewManager()
+	file := &File{Provider: "test", Path: "/foo.txt", Absent: true}
+	resources := Resources{
+		&dummyResource{absent: true},
+		&dummyResource{needsUpdate: true},
+		&dummyResource{},
+		&dummyResource{absent: true, createError: errors.New("cannot create")},
+		When(FactExists("missing"), file),
+	}
+
+	results, err := m.Apply(resources)
+	assert.Error(t, err)
+	require.Len(t, results, 4)
+	assert.True(t, results.Changes())
+	assert.True(t, results.Failed())
+
+	assert.Equal(t, ActionCreate, results[0].Action())
+	assert.Equal(t, resources[0], results[0].Resource())
+	assert.Equal(t, ActionUpdate, results[1].Action())
+	assert.Equal(t, ActionCreate, results[2].Action())
+	assert.Error(t, results[2].Err())
+	assert.Equal(t, ActionSkip, results[3].Action())
+	assert.Equal(t, reasonDisabled, results[3].Reason())
+	for _, result := range results {
+		assert.False(t, result.StartedAt().IsZero())
+		assert.GreaterOrEqual(t, result.Duration(), time.Duration(0))
+	}
+
+	d, err := json.Marshal(results)
+	require.NoError(t, err)
+
+	var report struct {
+		Summary map[string]int `json:"summary"`
+		Results []struct {
+			Resource string    `json:"resource"`
+			Action   string    `json:"action"`
+			Reason   string    `json:"reason"`
+			Error    string    `json:"error"`
+			Started  time.Time `json:"started"`
+		} `json:"results"`
+	}
+	err = json.Unmarshal(d, &report)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"created": 1, "updated": 1, "skipped": 1, "run": 0, "failed": 1}, report.Summary)
+	require.Len(t, report.Results, 4)
+	assert.Equal(t, "*resource.dummyResource", report.Results[0].Resource)
+	assert.Equal(t, ActionCreate, report.Results[0].Action)
+	assert.Equal(t, "cannot create", report.Results[2].Error)
+	assert.Equal(t, "[File:test:/foo.txt]", report.Results[3].Resource)
+	assert.Equal(t, ActionSkip, report.Results[3].Action)
+	assert.Equal(t, reasonDisabled, report.Results[3].Reason)
+	assert.Equal(t, results[3].StartedAt().UnixNano(), report.Results[3].Started.UnixNano())
+}
+
+func TestApplyResultsNoChanges(t *testing.T) {
+	var results ApplyResults
+	assert.False(t, results.Changes())
+	assert.False(t, results.Failed())
+
+	d, err := json.Marshal(results)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"summary":{"created":0,"updated":0,"skipped":0,"run":0,"failed":0},"results":[]}`, string(d))
+}
+
+func TestResourceID(t *testing.T) {
+	assert.Equal(t, "[File:file:/foo]", ResourceID(&File{Provider: "file", Path: "/foo"}))
+	assert.Equal(t, "[File:file:/foo]", ResourceID(When(FactExists("foo"), &File{Provider: "file", Path: "/foo"})))
+	assert.Equal(t, "*resource.dummyResource", ResourceID(&dummyResource{}))
+}
+
 func TestApplyError(t *testing.T) {
 	t.Run("nil error on empty list", func(t *testing.T) {
 		err := newApplyError([]error{})
@@ -95,17 +297,33 @@ func TestApplyError(t *testing.T) {
 type dummyResource struct {
 	absent      bool
 	needsUpdate bool
+	getError    error
 	createError error
+
+	dependencies Resources
+
+	created int
+	updated int
 }
 
 func (r *dummyResource) Get(context.Context, Scope) (ResourceState, error) {
+	if r.getError != nil {
+		return nil, r.getError
+	}
 	return &dummyResourceState{
 		absent:      r.absent,
 		needsUpdate: r.needsUpdate,
 	}, nil
 }
-func (r *dummyResource) Create(context.Context, Scope) error { return r.createError }
-func (r *dummyResource) Update(context.Context, Scope) error { return nil }
+func (r *dummyResource) Dependencies() Resources { return r.dependencies }
+func (r *dummyResource) Create(context.Context, Scope) error {
+	r.created++
+	return r.createError
+}
+func (r *dummyResource) Update(context.Context, Scope) error {
+	r.updated++
+	return nil
+}
 
 type dummyResourceState struct {
 	absent      bool
@@ -0,0 +1,756 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoSpace is returned by MemFS when writing more content than its capacity.
+var ErrNoSpace = errors.New("no space left on device")
+
+var (
+	errDirNotEmpty  = errors.New("directory not empty")
+	errNotDir       = errors.New("not a directory")
+	errIsDir        = errors.New("is a directory")
+	errNotLink      = errors.New("not a link")
+	errTooManyLinks = errors.New("too many levels of symbolic links")
+)
+
+// maxMemLinks is the maximum number of links followed when resolving a path
+// in a MemFS.
+const maxMemLinks = 40
+
+// MemFS is an in-memory writable file system. It can be used as the file system
+// of file providers to apply resources in tests without touching the disk.
+// Errors of real file systems can be simulated: writes fail with ErrNoSpace when
+// the capacity is exceeded, and changes in read-only paths fail with permission
+// errors. The zero value is an empty file system ready to use.
+type MemFS struct {
+	// Capacity is the maximum size of the content of all files, in bytes. If
+	// zero, there is no limit.
+	Capacity int64
+
+	mu       sync.Mutex
+	nodes    map[string]*memNode
+	readOnly []string
+	temps    int
+	uid, gid int
+}
+
+// NewMemFS returns a MemFS with a copy of the files, directories and links in
+// the given file system, keeping their permissions. It can be used to prepare
+// the initial state of tests from testdata directories or fstest.MapFS. Links
+// are copied if the file system implements fs.ReadLinkFS, otherwise they are
+// followed. If the file system is nil, an empty MemFS is returned.
+func NewMemFS(source fs.FS) (*MemFS, error) {
+	m := &MemFS{}
+	if source == nil {
+		return m, nil
+	}
+	if err := m.copyFS(source, "."); err != nil {
+		return nil, fmt.Errorf("failed to copy file system: %w", err)
+	}
+	return m, nil
+}
+
+// copyFS copies the content of a directory in the source file system.
+func (m *MemFS) copyFS(source fs.FS, root string) error {
+	return fs.WalkDir(source, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if linkFS, ok := source.(fs.ReadLinkFS); ok {
+				target, err := linkFS.ReadLink(name)
+				if err != nil {
+					return err
+				}
+				return m.Symlink(target, name)
+			}
+			info, err = fs.Stat(source, name)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				// Links to directories are not walked, copy their content.
+				if err := m.Mkdir(name, info.Mode().Perm()); err != nil {
+					return err
+				}
+				return m.copyFS(source, name)
+			}
+		}
+		if info.IsDir() {
+			return m.Mkdir(name, info.Mode().Perm())
+		}
+		d, err := fs.ReadFile(source, name)
+		if err != nil {
+			return err
+		}
+		return writeFile(m, name, d, info.Mode().Perm())
+	})
+}
+
+// memNode is a file, directory or link in a MemFS.
+type memNode struct {
+	mode    fs.FileMode
+	data    []byte
+	target  string
+	modTime time.Time
+	uid     int
+	gid     int
+}
+
+// memFileOwner is the system information of files in a MemFS.
+type memFileOwner struct {
+	uid, gid int
+}
+
+// SetReadOnly makes the named path, and everything below it, read-only. Any
+// change there fails with a permission error, as it happens when the user
+// doesn't have permissions to write.
+func (m *MemFS) SetReadOnly(name string) {
+	m.lock()
+	defer m.mu.Unlock()
+	m.readOnly = append(m.readOnly, memName(name))
+}
+
+// lock locks the file system, initializing it if needed.
+func (m *MemFS) lock() {
+	m.mu.Lock()
+	if m.nodes == nil {
+		m.uid, m.gid = os.Getuid(), os.Getgid()
+		m.nodes = map[string]*memNode{
+			".": m.newNode(fs.ModeDir | 0755),
+		}
+	}
+}
+
+func (m *MemFS) newNode(mode fs.FileMode) *memNode {
+	return &memNode{mode: mode, modTime: time.Now(), uid: m.uid, gid: m.gid}
+}
+
+// memName returns the clean name of a path in a MemFS.
+func memName(name string) string {
+	p := path.Clean("/" + filepath.ToSlash(name))
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// splitMemName returns the elements of a path.
+func splitMemName(name string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// resolve returns the name of the node a path refers to, following links in
+// the path. The last element is followed only if follow is true. The returned
+// node may not exist, but its parent does.
+func (m *MemFS) resolve(op, name string, follow bool) (string, error) {
+	parts := splitMemName(name)
+	resolved := "."
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		current := memName(path.Join(resolved, part))
+		node, found := m.nodes[current]
+		switch {
+		case !found:
+			if len(parts) > 0 {
+				return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+			}
+		case node.mode&fs.ModeSymlink != 0 && (len(parts) > 0 || follow):
+			links++
+			if links > maxMemLinks {
+				return "", &fs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+			}
+			target := filepath.ToSlash(node.target)
+			if path.IsAbs(target) {
+				resolved = "."
+			}
+			parts = append(splitMemName(target), parts...)
+			continue
+		case len(parts) > 0 && !node.mode.IsDir():
+			return "", &fs.PathError{Op: op, Path: name, Err: errNotDir}
+		}
+		resolved = current
+	}
+	return resolved, nil
+}
+
+// node returns the existing node a path refers to.
+func (m *MemFS) node(op, name string, follow bool) (string, *memNode, error) {
+	p, err := m.resolve(op, name, follow)
+	if err != nil {
+		return "", nil, err
+	}
+	node, found := m.nodes[p]
+	if !found {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return p, node, nil
+}
+
+// checkWritable returns an error if the resolved path cannot be changed.
+func (m *MemFS) checkWritable(op, name, p string) error {
+	for _, readOnly := range m.readOnly {
+		if readOnly == "." || p == readOnly || strings.HasPrefix(p, readOnly+"/") {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	}
+	return nil
+}
+
+// checkCreate returns an error if a node cannot be created in the resolved path.
+func (m *MemFS) checkCreate(op, name, p string) error {
+	if _, found := m.nodes[p]; found {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	parent, found := m.nodes[path.Dir(p)]
+	if !found {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return m.checkWritable(op, name, p)
+}
+
+// hasChildren returns true if there are nodes in the resolved path.
+func (m *MemFS) hasChildren(p string) bool {
+	for name := range m.nodes {
+		if name != "." && path.Dir(name) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// used returns the size of the content of all files.
+func (m *MemFS) used() int64 {
+	var used int64
+	for _, node := range m.nodes {
+		used += int64(len(node.data))
+	}
+	return used
+}
+
+// Stat returns information about the named file, following links.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return newMemFileInfo(p, node), nil
+}
+
+// Lstat returns information about the named file, without following links.
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return newMemFileInfo(p, node), nil
+}
+
+// Open opens the named file for reading.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &memReadFile{
+		Reader: bytes.NewReader(slices.Clone(node.data)),
+		info:   newMemFileInfo(p, node),
+	}, nil
+}
+
+// OpenFile opens the named file with the given flags, as os.OpenFile.
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	return m.openFile(name, flag, perm)
+}
+
+func (m *MemFS) openFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	const op = "open"
+	p, err := m.resolve(op, name, true)
+	if err != nil {
+		return nil, err
+	}
+	node, found := m.nodes[p]
+	switch {
+	case found && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	case found && node.mode.IsDir():
+		return nil, &fs.PathError{Op: op, Path: name, Err: errIsDir}
+	case !found && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case !found:
+		if err := m.checkCreate(op, name, p); err != nil {
+			return nil, err
+		}
+		node = m.newNode(perm.Perm())
+		m.nodes[p] = node
+	case flag&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC) != 0:
+		if err := m.checkWritable(op, name, p); err != nil {
+			return nil, err
+		}
+		if flag&os.O_TRUNC != 0 {
+			node.data = nil
+			node.modTime = time.Now()
+		}
+	}
+	return &memFile{
+		fs:     m,
+		name:   name,
+		path:   p,
+		node:   node,
+		append: flag&os.O_APPEND != 0,
+	}, nil
+}
+
+// CreateTemp creates a new temporary file in the directory, as os.CreateTemp.
+func (m *MemFS) CreateTemp(dir, pattern string) (WritableFile, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for {
+		m.temps++
+		name := filepath.Join(dir, prefix+strconv.Itoa(m.temps)+suffix)
+		f, err := m.openFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	var entries []fs.DirEntry
+	for child, node := range m.nodes {
+		if child != "." && path.Dir(child) == p {
+			entries = append(entries, fs.FileInfoToDirEntry(newMemFileInfo(child, node)))
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// Readlink returns the target of the named link.
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.lock()
+	defer m.mu.Unlock()
+	_, node, err := m.node("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: errNotLink}
+	}
+	return node.target, nil
+}
+
+// Mkdir creates a directory.
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.lock()
+	defer m.mu.Unlock()
+	return m.mkdir(name, perm)
+}
+
+func (m *MemFS) mkdir(name string, perm fs.FileMode) error {
+	p, err := m.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	if err := m.checkCreate("mkdir", name, p); err != nil {
+		return err
+	}
+	m.nodes[p] = m.newNode(fs.ModeDir | perm.Perm())
+	return nil
+}
+
+// MkdirAll creates a directory and any missing parent.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.lock()
+	defer m.mu.Unlock()
+	current := ""
+	for _, part := range splitMemName(name) {
+		current = path.Join(current, part)
+		p, err := m.resolve("mkdir", current, true)
+		if err != nil {
+			return err
+		}
+		node, found := m.nodes[p]
+		switch {
+		case found && node.mode.IsDir():
+			continue
+		case found:
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+		}
+		if err := m.mkdir(current, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes a file or an empty directory.
+func (m *MemFS) Remove(name string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if p == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if node.mode.IsDir() && m.hasChildren(p) {
+		return &fs.PathError{Op: "remove", Path: name, Err: errDirNotEmpty}
+	}
+	if err := m.checkWritable("remove", name, p); err != nil {
+		return err
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+// RemoveAll removes a path and any children it contains. It doesn't fail
+// if the path doesn't exist.
+func (m *MemFS) RemoveAll(name string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	p, err := m.resolve("removeall", name, false)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, found := m.nodes[p]; !found {
+		return nil
+	}
+	if err := m.checkWritable("removeall", name, p); err != nil {
+		return err
+	}
+	for child := range m.nodes {
+		switch {
+		case child == ".":
+		case p == "." || child == p || strings.HasPrefix(child, p+"/"):
+			delete(m.nodes, child)
+		}
+	}
+	return nil
+}
+
+// Rename renames a file, replacing the new one if it exists.
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	oldPath, oldNode, err := m.node("rename", oldname, false)
+	if err != nil {
+		return linkErr(fs.ErrNotExist)
+	}
+	newPath, err := m.resolve("rename", newname, false)
+	if err != nil {
+		return linkErr(fs.ErrNotExist)
+	}
+	if oldPath == newPath {
+		return nil
+	}
+	if oldPath == "." || strings.HasPrefix(newPath, oldPath+"/") {
+		return linkErr(fs.ErrInvalid)
+	}
+	if err := m.checkWritable("rename", oldname, oldPath); err != nil {
+		return linkErr(fs.ErrPermission)
+	}
+	if newNode, found := m.nodes[newPath]; found {
+		switch {
+		case newNode.mode.IsDir() && !oldNode.mode.IsDir():
+			return linkErr(errIsDir)
+		case !newNode.mode.IsDir() && oldNode.mode.IsDir():
+			return linkErr(errNotDir)
+		case newNode.mode.IsDir() && m.hasChildren(newPath):
+			return linkErr(errDirNotEmpty)
+		}
+		if err := m.checkWritable("rename", newname, newPath); err != nil {
+			return linkErr(fs.ErrPermission)
+		}
+		delete(m.nodes, newPath)
+	}
+	if err := m.checkCreate("rename", newname, newPath); err != nil {
+		return linkErr(errors.Unwrap(err))
+	}
+
+	for child, node := range m.nodes {
+		if rest, found := strings.CutPrefix(child, oldPath+"/"); found {
+			delete(m.nodes, child)
+			m.nodes[newPath+"/"+rest] = node
+		}
+	}
+	delete(m.nodes, oldPath)
+	m.nodes[newPath] = oldNode
+	return nil
+}
+
+// Symlink creates a link with the new name pointing to the old name.
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	p, err := m.resolve("symlink", newname, false)
+	if err != nil {
+		return err
+	}
+	if err := m.checkCreate("symlink", newname, p); err != nil {
+		return err
+	}
+	node := m.newNode(fs.ModeSymlink | 0777)
+	node.target = oldname
+	m.nodes[p] = node
+	return nil
+}
+
+// Chmod changes the mode of the named file.
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	if err := m.checkWritable("chmod", name, p); err != nil {
+		return err
+	}
+	node.mode = node.mode.Type() | mode.Perm()
+	return nil
+}
+
+// Lchown changes the owner and the group of the named file, without following
+// links. An id of -1 keeps the current value.
+func (m *MemFS) Lchown(name string, uid, gid int) error {
+	m.lock()
+	defer m.mu.Unlock()
+	p, node, err := m.node("lchown", name, false)
+	if err != nil {
+		return err
+	}
+	if err := m.checkWritable("lchown", name, p); err != nil {
+		return err
+	}
+	node.chown(uid, gid)
+	return nil
+}
+
+// SyncDir checks that the named directory exists, there is nothing to flush.
+func (m *MemFS) SyncDir(name string) error {
+	m.lock()
+	defer m.mu.Unlock()
+	_, node, err := m.node("sync", name, true)
+	if err != nil {
+		return err
+	}
+	if !node.mode.IsDir() {
+		return &fs.PathError{Op: "sync", Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+func (n *memNode) chown(uid, gid int) {
+	if uid >= 0 {
+		n.uid = uid
+	}
+	if gid >= 0 {
+		n.gid = gid
+	}
+}
+
+// memFile is a file opened for writing in a MemFS.
+type memFile struct {
+	fs     *MemFS
+	name   string
+	path   string
+	node   *memNode
+	append bool
+	offset int
+	closed bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fs.lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+	}
+	if err := f.fs.checkWritable("write", f.name, f.path); err != nil {
+		return 0, err
+	}
+	if f.append {
+		f.offset = len(f.node.data)
+	}
+	end := f.offset + len(b)
+	if growth := end - len(f.node.data); growth > 0 {
+		if f.fs.Capacity > 0 && f.fs.used()+int64(growth) > f.fs.Capacity {
+			return 0, &fs.PathError{Op: "write", Path: f.name, Err: ErrNoSpace}
+		}
+		f.node.data = append(f.node.data, make([]byte, growth)...)
+	}
+	copy(f.node.data[f.offset:], b)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fs.lock()
+	defer f.fs.mu.Unlock()
+	return newMemFileInfo(f.path, f.node), nil
+}
+
+func (f *memFile) Sync() error {
+	f.fs.lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Chmod(mode fs.FileMode) error {
+	f.fs.lock()
+	defer f.fs.mu.Unlock()
+	if err := f.fs.checkWritable("chmod", f.name, f.path); err != nil {
+		return err
+	}
+	f.node.mode = f.node.mode.Type() | mode.Perm()
+	return nil
+}
+
+func (f *memFile) Chown(uid, gid int) error {
+	f.fs.lock()
+	defer f.fs.mu.Unlock()
+	if err := f.fs.checkWritable("chown", f.name, f.path); err != nil {
+		return err
+	}
+	f.node.chown(uid, gid)
+	return nil
+}
+
+// memReadFile is a file opened for reading in a MemFS.
+type memReadFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memReadFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memReadFile) Close() error {
+	return nil
+}
+
+// memFileInfo is the information about a file in a MemFS.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	owner   memFileOwner
+}
+
+func newMemFileInfo(p string, node *memNode) *memFileInfo {
+	return &memFileInfo{
+		name:    path.Base(p),
+		size:    int64(len(node.data)),
+		mode:    node.mode,
+		modTime: node.modTime,
+		owner:   memFileOwner{uid: node.uid, gid: node.gid},
+	}
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return i.owner }
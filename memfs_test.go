@@ -0,0 +1,380 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFSFile(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	mode := fs.FileMode(0600)
+	resource := File{
+		Path:         "/etc/app/config.yml",
+		CreateParent: true,
+		Mode:         &mode,
+		Content:      FileContentLiteral("name: sample\n"),
+	}
+	result, err := manager.Apply(Resources{&resource})
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionCreate, result[0].Action())
+	}
+
+	d, err := readFile(fsys, "etc/app/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: sample\n", string(d))
+
+	info, err := fsys.Stat("/etc/app/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0600), info.Mode())
+
+	entries, err := fsys.ReadDir("/etc/app")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files should be removed")
+
+	resource.Content = FileContentLiteral("name: other\n")
+	result, err = manager.Apply(Resources{&resource})
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].Action())
+	}
+	d, err = readFile(fsys, "etc/app/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: other\n", string(d))
+
+	// On second apply, it should do nothing.
+	result, err = manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestMemFSOwner(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	resource := File{
+		Path:    "/config.yml",
+		Owner:   "1234",
+		Group:   "5678",
+		Content: FileContentLiteral("content"),
+	}
+	_, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+
+	info, err := fsys.Stat("/config.yml")
+	require.NoError(t, err)
+	uid, gid, found := fileOwner(info)
+	require.True(t, found)
+	assert.Equal(t, 1234, uid)
+	assert.Equal(t, 5678, gid)
+
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestMemFSSymlink(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	resources := Resources{
+		&File{Path: "/opt/app-1.0", Directory: true, CreateParent: true},
+		&File{Path: "/opt/app-1.0/version", Content: FileContentLiteral("1.0")},
+		&Symlink{Path: "/opt/app", Target: "app-1.0"},
+	}
+	_, err := manager.Apply(resources)
+	require.NoError(t, err)
+
+	target, err := fsys.Readlink("/opt/app")
+	require.NoError(t, err)
+	assert.Equal(t, "app-1.0", target)
+
+	d, err := readFile(fsys, "/opt/app/version")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", string(d))
+
+	info, err := fsys.Lstat("/opt/app")
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&fs.ModeSymlink)
+
+	result, err := manager.Apply(resources)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestMemFSDirectoryPurge(t *testing.T) {
+	fsys := &MemFS{}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	require.NoError(t, fsys.MkdirAll("config/extradir", 0755))
+	require.NoError(t, writeFile(fsys, "config/extradir/extra.txt", []byte("extra"), 0644))
+	require.NoError(t, writeFile(fsys, "config/extra.txt", []byte("extra"), 0644))
+
+	resource := Directory{
+		Path:   "config",
+		Source: NewSourceFS(os.DirFS("testdata/directory/config")),
+		Purge:  true,
+	}
+	result, err := manager.Apply(Resources{&resource})
+	t.Log(result)
+	require.NoError(t, err)
+
+	for _, path := range []string{"config/extra.txt", "config/extradir"} {
+		_, err := fsys.Stat(path)
+		assert.ErrorIs(t, err, fs.ErrNotExist, path)
+	}
+	_, err = fsys.Stat("config/sub/hello.txt.tmpl")
+	assert.NoError(t, err)
+}
+
+func TestMemFSPermissionDenied(t *testing.T) {
+	fsys := &MemFS{}
+	require.NoError(t, fsys.Mkdir("/etc", 0755))
+	require.NoError(t, writeFile(fsys, "/etc/config.yml", []byte("old"), 0644))
+	fsys.SetReadOnly("/etc")
+
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	resources := Resources{
+		&File{Path: "/etc/config.yml", Content: FileContentLiteral("new")},
+		&File{Path: "/etc/other.yml", Content: FileContentLiteral("new")},
+		&File{Path: "/tmp", Directory: true},
+	}
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	require.Len(t, result, 3)
+	assert.ErrorIs(t, result[0].Err(), fs.ErrPermission)
+	assert.ErrorIs(t, result[1].Err(), fs.ErrPermission)
+	assert.NoError(t, result[2].Err())
+
+	d, err := readFile(fsys, "/etc/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(d))
+}
+
+func TestMemFSDiskFull(t *testing.T) {
+	fsys := &MemFS{Capacity: 10}
+	require.NoError(t, writeFile(fsys, "/config.yml", []byte("old"), 0644))
+
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	resource := File{
+		Path:    "/config.yml",
+		Content: FileContentLiteral("some content larger than the capacity"),
+	}
+	result, err := manager.Apply(Resources{&resource})
+	t.Log(result)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNoSpace)
+
+	d, err := readFile(fsys, "/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(d))
+
+	entries, err := fsys.ReadDir("/")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files should be removed")
+
+	// Content fits if it replaces the old one.
+	resource.Content = FileContentLiteral("new")
+	_, err = manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+}
+
+func TestMemFSTransaction(t *testing.T) {
+	fsys := &MemFS{}
+	require.NoError(t, writeFile(fsys, "/config.yml", []byte("old"), 0640))
+	fsys.SetReadOnly("/readonly")
+
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+	manager.SetTransactional(true)
+
+	resources := Resources{
+		&File{Path: "/config.yml", Content: FileContentLiteral("new")},
+		&File{Path: "/new/file.yml", CreateParent: true, Content: FileContentLiteral("new")},
+		&File{Path: "/readonly", Directory: true},
+	}
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	d, err := readFile(fsys, "/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(d))
+	info, err := fsys.Stat("/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0640), info.Mode())
+
+	_, err = fsys.Stat("/new")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestMemFSBackup(t *testing.T) {
+	fsys := &MemFS{}
+	require.NoError(t, writeFile(fsys, "/config.yml", []byte("old"), 0644))
+
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{
+		FS:     fsys,
+		Backup: &FileBackup{Dir: "/backups"},
+	})
+
+	resource := File{Path: "/config.yml", Content: FileContentLiteral("new")}
+	result, err := manager.Apply(Resources{&resource})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Len(t, result[0].Backups(), 1)
+
+	d, err := readFile(fsys, result[0].Backups()[0])
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(d))
+}
+
+func TestMemFSOperations(t *testing.T) {
+	fsys := &MemFS{}
+
+	require.NoError(t, fsys.MkdirAll("/a/b", 0755))
+	require.NoError(t, writeFile(fsys, "/a/b/file", []byte("content"), 0644))
+	require.NoError(t, fsys.Symlink("/a/b", "/link"))
+	require.NoError(t, fsys.Symlink("../b/file", "/a/b/relative"))
+
+	d, err := readFile(fsys, "/link/file")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(d))
+
+	d, err = readFile(fsys, "/link/relative")
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(d))
+
+	err = fsys.Mkdir("/a/b", 0755)
+	assert.ErrorIs(t, err, fs.ErrExist)
+
+	err = fsys.Mkdir("/missing/dir", 0755)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	err = fsys.Remove("/a")
+	assert.ErrorIs(t, err, errDirNotEmpty)
+
+	err = fsys.Rename("/a", "/c")
+	require.NoError(t, err)
+	_, err = fsys.Stat("/c/b/file")
+	assert.NoError(t, err)
+	_, err = fsys.Stat("/link/file")
+	assert.ErrorIs(t, err, fs.ErrNotExist, "link should be broken")
+
+	_, err = fsys.Lstat("/link")
+	assert.NoError(t, err)
+
+	require.NoError(t, fsys.Symlink("/loop", "/loop"))
+	_, err = fsys.Stat("/loop")
+	assert.ErrorIs(t, err, errTooManyLinks)
+
+	require.NoError(t, fsys.RemoveAll("/c"))
+	require.NoError(t, fsys.RemoveAll("/c"))
+	entries, err := fsys.ReadDir("/")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"link", "loop"}, names)
+}
+
+func TestNewMemFS(t *testing.T) {
+	source := fstest.MapFS{
+		"etc":                 {Mode: fs.ModeDir | 0750},
+		"etc/app/config.yml":  {Data: []byte("name: sample\n"), Mode: 0600},
+		"etc/app/current.yml": {Data: []byte("config.yml"), Mode: fs.ModeSymlink | 0777},
+		"README":              {Data: []byte("readme\n"), Mode: 0644},
+	}
+	fsys, err := NewMemFS(source)
+	require.NoError(t, err)
+
+	d, err := readFile(fsys, "/etc/app/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: sample\n", string(d))
+
+	info, err := fsys.Stat("/etc/app/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0600), info.Mode())
+
+	info, err = fsys.Stat("/etc")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeDir|0750, info.Mode())
+
+	target, err := fsys.Readlink("/etc/app/current.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "config.yml", target)
+	d, err = readFile(fsys, "/etc/app/current.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: sample\n", string(d))
+
+	// Changes don't modify the source.
+	require.NoError(t, writeFile(fsys, "/README", []byte("changed"), 0644))
+	assert.Equal(t, "readme\n", string(source["README"].Data))
+}
+
+func TestNewMemFSFromTestdata(t *testing.T) {
+	fsys, err := NewMemFS(os.DirFS("testdata/directory"))
+	require.NoError(t, err)
+
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &FileProvider{FS: fsys})
+
+	resource := File{
+		Path:    "/config/app.yml",
+		Content: FileContentLiteral("name: sample\n"),
+	}
+	state, err := resource.Get(context.Background(), manager)
+	require.NoError(t, err)
+	assert.True(t, state.Found(context.Background()))
+	needsUpdate, err := state.NeedsUpdate(context.Background(), &resource)
+	require.NoError(t, err)
+	assert.False(t, needsUpdate)
+}
+
+func TestNewMemFSNil(t *testing.T) {
+	fsys, err := NewMemFS(nil)
+	require.NoError(t, err)
+
+	entries, err := fsys.ReadDir("/")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,297 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Module is a named collection of resources. Modules can be included in
+// collections of resources as any other resource, and they can be nested.
+// When applied, the resources in the module can use the facts and providers
+// defined in the module, in addition to the ones defined in the manager.
+//
+// Reusable modules can be defined with functions that receive the parameters
+// and return a module, or by setting different Params to copies of the module.
+type Module struct {
+	// Name is the name of the module. Results of the resources in the module
+	// report the path of names of the modules they are defined in.
+	Name string
+	// Params are the parameters of the module. They are available as facts
+	// to the resources in the module, with precedence over any other fact.
+	Params map[string]string
+	// Facters are additional facters available to the resources in the module.
+	// They have precedence over facters defined out of the module. Facters later
+	// in the list have precedence.
+	Facters []Facter
+	// Providers are providers available to the resources in the module. They
+	// override providers with the same name defined out of the module.
+	Providers map[string]Provider
+	// Resources are the resources managed by the module, they can include other
+	// modules.
+	Resources Resources
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("[Module:%s]", m.Name)
+}
+
+// Get returns an error, modules are expanded by the manager, they cannot be
+// managed as single resources.
+func (m *Module) Get(context.Context, Scope) (ResourceState, error) {
+	return nil, m.errNotExpanded()
+}
+
+// Create returns an error, modules are expanded by the manager, they cannot be
+// managed as single resources.
+func (m *Module) Create(context.Context, Scope) error {
+	return m.errNotExpanded()
+}
+
+// Update returns an error, modules are expanded by the manager, they cannot be
+// managed as single resources.
+func (m *Module) Update(context.Context, Scope) error {
+	return m.errNotExpanded()
+}
+
+func (m *Module) errNotExpanded() error {
+	return fmt.Errorf("module %s must be directly included in the resources applied by a manager", m.Name)
+}
+
+// modulePath returns the path of a module included in a module with the given path.
+func modulePath(parent string, module *Module) string {
+	switch {
+	case parent == "":
+		return module.Name
+	case module.Name == "":
+		return parent
+	default:
+		return parent + "/" + module.Name
+	}
+}
+
+// moduleScope is the scope of the resources in a module.
+type moduleScope struct {
+	parent Scope
+	module *Module
+}
+
+// Provider obtains a provider and sets it in the target. Providers defined in
+// the module have precedence over the ones in the parent scope.
+func (s *moduleScope) Provider(name string, target any) bool {
+	if p, found := s.module.Providers[name]; found {
+		return setProvider(p, target)
+	}
+	return s.parent.Provider(name, target)
+}
+
+// Fact returns the value of a fact for a given name and true if it is found.
+// Module parameters have precedence, then facts in the module facters, and
+// then facts in the parent scope.
+func (s *moduleScope) Fact(name string) (string, bool) {
+	if v, found := s.module.Params[name]; found {
+		return v, true
+	}
+	for i := len(s.module.Facters) - 1; i >= 0; i-- {
+		if v, found := s.module.Facters[i].Fact(name); found {
+			return v, true
+		}
+	}
+	return s.parent.Fact(name)
+}
+
+// FactValue returns the value of a fact for a given name and true if it is found,
+// with the same precedence as Fact.
+func (s *moduleScope) FactValue(name string) (any, bool) {
+	if v, found := s.module.Params[name]; found {
+		return v, true
+	}
+	for i := len(s.module.Facters) - 1; i >= 0; i-- {
+		if v, found := factValue(s.module.Facters[i], name); found {
+			return v, true
+		}
+	}
+	return s.parent.FactValue(name)
+}
+
+// factersErr returns the errors reported by the facters of the module and of
+// the parent scope.
+func (s *moduleScope) factersErr() error {
+	var parentErr error
+	if parent, ok := s.parent.(interface{ factersErr() error }); ok {
+		parentErr = parent.factersErr()
+	}
+	return errors.Join(factersErr(s.module.Facters), parentErr)
+}
+
+// FactBool returns the value of a fact as a boolean.
+func (s *moduleScope) FactBool(name string) (bool, error) {
+	return factBool(s, name)
+}
+
+// FactInt returns the value of a fact as an integer.
+func (s *moduleScope) FactInt(name string) (int, error) {
+	return factInt(s, name)
+}
+
+// FactList returns the value of a fact as a list of strings.
+func (s *moduleScope) FactList(name string) ([]string, error) {
+	return factList(s, name)
+}
+
+// ExpandableResource is implemented by resources that are managed as collections
+// of other resources. They are expanded by the manager before applying them, and
+// the result of each one of the expanded resources is reported.
+type ExpandableResource interface {
+	Resource
+
+	// Expand returns the resources to apply in place of this resource.
+	Expand(context.Context, Scope) (Resources, error)
+}
+
+// expandsResources returns true if the resource, or the resource wrapped by
+// conditions, is expanded into other resources.
+func expandsResources(resource Resource) bool {
+	for {
+		conditional, ok := resource.(*conditionalResource)
+		if !ok {
+			break
+		}
+		resource = conditional.resource
+	}
+	switch resource.(type) {
+	case *Module, ExpandableResource:
+		return true
+	default:
+		return false
+	}
+}
+
+// expandResources returns the nodes for a collection of resources, including
+// the ones defined in modules and expandable resources. It also returns an index
+// to find the nodes by their resources, modules and expandable resources are
+// indexed with all the nodes they expand to.
+func expandResources(ctx context.Context, resources Resources, scope Scope) ([]*resourceNode, map[Resource][]*resourceNode, error) {
+	var nodes []*resourceNode
+	index := make(map[Resource][]*resourceNode)
+	expanding := make(map[*Module]bool)
+
+	addNode := func(node *resourceNode) {
+		nodes = append(nodes, node)
+
+		// Wrapped resources can be also referenced as dependencies.
+		for r := node.resource; r != nil; r = unwrapResource(r) {
+			if !isComparableResource(r) {
+				continue
+			}
+			if _, found := index[r]; !found {
+				index[r] = []*resourceNode{node}
+			}
+		}
+	}
+
+	var expand func(resources Resources, scope Scope, path string) ([]*resourceNode, error)
+	expand = func(resources Resources, scope Scope, path string) ([]*resourceNode, error) {
+		var expanded []*resourceNode
+		for _, resource := range resources {
+			switch resource := resource.(type) {
+			case *conditionalResource:
+				if !expandsResources(resource) {
+					node := &resourceNode{
+						resource: resource,
+						scope:    scope,
+						module:   path,
+					}
+					addNode(node)
+					expanded = append(expanded, node)
+					continue
+				}
+
+				// The condition is applied to each one of the expanded resources,
+				// evaluated in the scope where it is defined.
+				conditionalNodes, err := expand(Resources{resource.resource}, scope, path)
+				if err != nil {
+					return nil, err
+				}
+				condition := func(Scope) (bool, error) {
+					return resource.condition(scope)
+				}
+				for _, node := range conditionalNodes {
+					node.resource = &conditionalResource{condition: condition, resource: node.resource}
+				}
+				if _, found := index[resource]; !found {
+					index[resource] = conditionalNodes
+				}
+				expanded = append(expanded, conditionalNodes...)
+			case *Module:
+				if expanding[resource] {
+					return nil, fmt.Errorf("module %s includes itself", resource)
+				}
+				expanding[resource] = true
+				moduleNodes, err := expand(resource.Resources, &moduleScope{parent: scope, module: resource}, modulePath(path, resource))
+				if err != nil {
+					return nil, err
+				}
+				expanding[resource] = false
+
+				if _, found := index[resource]; !found {
+					index[resource] = moduleNodes
+				}
+				expanded = append(expanded, moduleNodes...)
+			case ExpandableResource:
+				resources, err := resource.Expand(ctx, scope)
+				if err != nil {
+					node := &resourceNode{
+						resource: resource,
+						scope:    scope,
+						module:   path,
+						err:      fmt.Errorf("failed to expand %s: %w", resource, err),
+					}
+					addNode(node)
+					expanded = append(expanded, node)
+					continue
+				}
+				resourceNodes, err := expand(resources, scope, path)
+				if err != nil {
+					return nil, err
+				}
+				if _, found := index[resource]; !found {
+					index[resource] = resourceNodes
+				}
+				expanded = append(expanded, resourceNodes...)
+			default:
+				node := &resourceNode{
+					resource: resource,
+					scope:    scope,
+					module:   path,
+				}
+				addNode(node)
+				expanded = append(expanded, node)
+			}
+		}
+		return expanded, nil
+	}
+
+	_, err := expand(resources, scope, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return nodes, index, nil
+}
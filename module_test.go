@@ -0,0 +1,164 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	moduleProvider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+	manager.AddFacter(StaticFacter{
+		"sample": "managerfact",
+		"other":  "managerfact",
+	})
+
+	funcs := template.FuncMap{
+		"sayHello": func() string { return "Hello!" },
+	}
+	source := NewSourceFS(os.DirFS("testdata/templates")).WithTemplateFuncs(funcs)
+	sampleModule := func(name, fact string) *Module {
+		return &Module{
+			Name:   name,
+			Params: map[string]string{"sample": fact},
+			Resources: Resources{
+				&File{
+					Path:    name + ".txt",
+					Content: source.Template("sample-file.txt.tmpl"),
+				},
+			},
+		}
+	}
+
+	outer := &Module{
+		Name: "outer",
+		Facters: []Facter{
+			StaticFacter{"other": "modulefact"},
+		},
+		Providers: map[string]Provider{
+			defaultFileProviderName: &moduleProvider,
+		},
+		Resources: Resources{
+			sampleModule("first", "firstfact"),
+			sampleModule("second", "secondfact"),
+		},
+	}
+	afterModule := &File{
+		Path:      "after.txt",
+		DependsOn: Resources{outer},
+	}
+	resources := Resources{afterModule, outer}
+
+	results, err := manager.Apply(resources)
+	t.Log(results)
+	require.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, "outer/first", results[0].Module())
+		assert.Equal(t, "outer/second", results[1].Module())
+		assert.Equal(t, "", results[2].Module())
+		assert.Equal(t, afterModule, results[2].resource)
+		assert.Equal(t, "{create: [File::first.txt], module: outer/first}", results[0].String())
+	}
+
+	_, err = os.Stat(filepath.Join(provider.Prefix, afterModule.Path))
+	assert.NoError(t, err)
+
+	for name, fact := range map[string]string{"first": "firstfact", "second": "secondfact"} {
+		d, err := os.ReadFile(filepath.Join(moduleProvider.Prefix, name+".txt"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Hello! This is a template with a fact: "+fact+"\n", string(d))
+		}
+	}
+}
+
+func TestModuleScope(t *testing.T) {
+	manager := NewManager()
+	manager.RegisterProvider("file", &FileProvider{Prefix: "manager"})
+	manager.RegisterProvider("other", &FileProvider{Prefix: "other"})
+	manager.AddFacter(StaticFacter{
+		"param":  "managerfact",
+		"facter": "managerfact",
+		"global": "managerfact",
+	})
+
+	scope := &moduleScope{
+		parent: manager,
+		module: &Module{
+			Params: map[string]string{"param": "param"},
+			Facters: []Facter{
+				StaticFacter{"facter": "first", "param": "first"},
+				StaticFacter{"facter": "second"},
+			},
+			Providers: map[string]Provider{
+				"file": &FileProvider{Prefix: "module"},
+			},
+		},
+	}
+
+	for name, expected := range map[string]string{"param": "param", "facter": "second", "global": "managerfact"} {
+		value, found := scope.Fact(name)
+		assert.True(t, found)
+		assert.Equal(t, expected, value, name)
+	}
+	_, found := scope.Fact("notfound")
+	assert.False(t, found)
+
+	manager.AddFacter(StaticValuesFacter{"typed": 42})
+	value, found := scope.FactValue("typed")
+	assert.True(t, found)
+	assert.Equal(t, 42, value)
+	value, found = scope.FactValue("param")
+	assert.True(t, found)
+	assert.Equal(t, "param", value)
+
+	var provider *FileProvider
+	if assert.True(t, scope.Provider("file", &provider)) {
+		assert.Equal(t, "module", provider.Prefix)
+	}
+	if assert.True(t, scope.Provider("other", &provider)) {
+		assert.Equal(t, "other", provider.Prefix)
+	}
+}
+
+func TestModuleIncludesItself(t *testing.T) {
+	module := &Module{Name: "recursive"}
+	module.Resources = Resources{&Module{Name: "inner", Resources: Resources{module}}}
+
+	_, err := NewManager().Apply(Resources{module})
+	assert.ErrorContains(t, err, "module [Module:recursive] includes itself")
+}
+
+func TestModuleNotExpanded(t *testing.T) {
+	module := &Module{Name: "sample"}
+	_, err := module.Get(context.Background(), NewManager())
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// Symlink is a resource that manages a symbolic link. It uses file providers,
+// so the path of the link is relative to the prefix of the provider.
+type Symlink struct {
+	// Provider is the name of the file provider to use, defaults to "file".
+	Provider string `yaml:"provider"`
+	// Path is the path of the link.
+	Path string `yaml:"path"`
+	// Target is the path the link points to. It is used as is, so relative
+	// targets are relative to the directory containing the link.
+	Target string `yaml:"target"`
+	// Absent is set to true to indicate that the link should not exist. If it
+	// exists, the link is removed.
+	Absent bool `yaml:"absent"`
+	// Force forces destructive operations, such as removing a file or a directory
+	// to replace it with the link. These operations will fail if force is not set.
+	Force bool `yaml:"force"`
+	// DependsOn is the list of resources that need to be applied before this link.
+	DependsOn Resources `yaml:"-"`
+	// Notify is the list of handlers to run when this link is created or updated.
+	Notify []*Handler `yaml:"-"`
+}
+
+func (s *Symlink) String() string {
+	return fmt.Sprintf("[Symlink:%s:%s]", s.Provider, s.Path)
+}
+
+// Dependencies returns the resources this link depends on.
+func (s *Symlink) Dependencies() Resources {
+	return s.DependsOn
+}
+
+// Notifications returns the handlers to run when this link changes.
+func (s *Symlink) Notifications() []*Handler {
+	return s.Notify
+}
+
+// Identity returns the identity of the link, formed by the provider and the
+// complete path of the link.
+func (s *Symlink) Identity(scope Scope) ResourceIdentity {
+	provider := s.Provider
+	if provider == "" {
+		provider = defaultFileProviderName
+	}
+	return ResourceIdentity{
+		Type:     "Symlink",
+		Provider: provider,
+		Key:      fileProvider(scope, s.Provider).path(s.Path),
+	}
+}
+
+// Snapshot records the state of the path of the link, so it can be restored.
+// Directories with content that would be replaced cannot be reverted.
+func (s *Symlink) Snapshot(_ context.Context, scope Scope) (ResourceSnapshot, error) {
+	return snapshotPath(fileProvider(scope, s.Provider).fs(), s.Path, true)
+}
+
+// Validate checks that the definition of the link is consistent.
+func (s *Symlink) Validate(scope Scope) error {
+	var errs []error
+	if s.Path == "" {
+		errs = append(errs, errors.New("path is required"))
+	} else if err := fileProvider(scope, s.Provider).checkPath(s.Path); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Target == "" && !s.Absent {
+		errs = append(errs, errors.New("target is required"))
+	}
+	if s.Provider != "" {
+		var provider *FileProvider
+		if !scope.Provider(s.Provider, &provider) {
+			errs = append(errs, fmt.Errorf("file provider %q not found", s.Provider))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Symlink) Get(ctx context.Context, scope Scope) (current ResourceState, err error) {
+	fsys := fileProvider(scope, s.Provider).fs()
+	info, err := fsys.Lstat(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &SymlinkState{expected: !s.Absent}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	state := SymlinkState{
+		info:     info,
+		expected: !s.Absent,
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		state.target, err = fsys.Readlink(s.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link: %w", err)
+		}
+	}
+	return &state, nil
+}
+
+func (s *Symlink) Create(ctx context.Context, scope Scope) error {
+	err := fileProvider(scope, s.Provider).fs().Symlink(s.Target, s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create link: %w", err)
+	}
+	return nil
+}
+
+func (s *Symlink) Update(ctx context.Context, scope Scope) error {
+	provider := fileProvider(scope, s.Provider)
+	fsys := provider.fs()
+	if s.Absent {
+		info, err := fsys.Lstat(s.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fsys.Remove(s.Path)
+		}
+		if !s.Force {
+			return fmt.Errorf("%s exists and is not a link, use force to remove it", provider.path(s.Path))
+		}
+		if err := provider.backup(ctx, s.Path); err != nil {
+			return err
+		}
+		return fsys.RemoveAll(s.Path)
+	}
+
+	info, err := fsys.Lstat(s.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if info != nil {
+		if info.Mode()&fs.ModeSymlink == 0 && !s.Force {
+			return fmt.Errorf("%s exists and is not a link, use force to replace it", provider.path(s.Path))
+		}
+		if err := provider.backup(ctx, s.Path); err != nil {
+			return err
+		}
+		err := fsys.RemoveAll(s.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.Create(ctx, scope)
+}
+
+// SymlinkState is the state of a symbolic link.
+type SymlinkState struct {
+	info     fs.FileInfo
+	target   string
+	expected bool
+}
+
+func (s *SymlinkState) Found(context.Context) bool {
+	return s.info != nil || !s.expected
+}
+
+func (s *SymlinkState) NeedsUpdate(ctx context.Context, resource Resource) (bool, error) {
+	link := resource.(*Symlink)
+	if s.info == nil {
+		return false, nil
+	}
+	if link.Absent {
+		return true, nil
+	}
+	if s.info.Mode()&fs.ModeSymlink == 0 {
+		return true, nil
+	}
+	return s.target != link.Target, nil
+}
@@ -0,0 +1,222 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymlinkPresent(t *testing.T) {
+	skipSymlinkTestsOnWindows(t)
+
+	providerName := "test-files"
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(providerName, &provider)
+
+	resource := Symlink{
+		Provider: providerName,
+		Path:     "/sample-link",
+		Target:   "sample-file.txt",
+	}
+	resources := Resources{&resource}
+
+	state, err := resource.Get(context.Background(), manager)
+	require.NoError(t, err)
+	assert.False(t, state.Found(context.Background()))
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionCreate, result[0].action)
+	}
+
+	target, err := os.Readlink(filepath.Join(provider.Prefix, resource.Path))
+	require.NoError(t, err)
+	assert.Equal(t, resource.Target, target)
+
+	// On second apply, it should do nothing.
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestSymlinkWrongTarget(t *testing.T) {
+	skipSymlinkTestsOnWindows(t)
+
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := Symlink{
+		Path:   "sample-link",
+		Target: "sample-file.txt",
+	}
+	resources := Resources{&resource}
+
+	path := filepath.Join(provider.Prefix, resource.Path)
+	require.NoError(t, os.Symlink("other-file.txt", path))
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+	}
+
+	target, err := os.Readlink(path)
+	require.NoError(t, err)
+	assert.Equal(t, resource.Target, target)
+}
+
+func TestSymlinkReplaceFile(t *testing.T) {
+	skipSymlinkTestsOnWindows(t)
+
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := Symlink{
+		Path:   "sample-link",
+		Target: "sample-file.txt",
+	}
+	resources := Resources{&resource}
+
+	path := filepath.Join(provider.Prefix, resource.Path)
+	require.NoError(t, os.WriteFile(path, []byte("some content"), 0644))
+
+	// Without force, a regular file is not replaced.
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	assert.Error(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+		assert.Error(t, result[0].Err())
+	}
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+
+	resource.Force = true
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+	}
+
+	target, err := os.Readlink(path)
+	require.NoError(t, err)
+	assert.Equal(t, resource.Target, target)
+}
+
+func TestSymlinkAbsent(t *testing.T) {
+	skipSymlinkTestsOnWindows(t)
+
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := Symlink{
+		Path:   "sample-link",
+		Target: "sample-file.txt",
+		Absent: true,
+	}
+	resources := Resources{&resource}
+
+	path := filepath.Join(provider.Prefix, resource.Path)
+	require.NoError(t, os.Symlink(resource.Target, path))
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+	}
+
+	_, err = os.Lstat(path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// On second apply, it should do nothing.
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func skipSymlinkTestsOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symbolic links require special privileges on Windows")
+	}
+}
+
+func TestSymlinkAbsentRegularFile(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := Symlink{
+		Path:   "important",
+		Absent: true,
+	}
+	resources := Resources{&resource}
+
+	path := filepath.Join(provider.Prefix, resource.Path)
+	require.NoError(t, os.WriteFile(path, []byte("important content"), 0644))
+
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "exists and is not a link, use force to remove it")
+
+	d, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "important content", string(d))
+
+	// With force, the file is removed.
+	resource.Force = true
+	result, err = manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, ActionUpdate, result[0].action)
+	}
+
+	_, err = os.Lstat(path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
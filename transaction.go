@@ -0,0 +1,252 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotRevertible is returned by resources that cannot record their state to
+// revert changes.
+var ErrNotRevertible = errors.New("resource cannot be reverted")
+
+// RevertibleResource is implemented by resources whose changes can be reverted.
+// In transactional mode, all the resources applied must be revertible.
+type RevertibleResource interface {
+	Resource
+
+	// Snapshot records the current state of the resource, so it can be restored
+	// if the transaction fails. It is called before creating or updating the
+	// resource. It returns an error wrapping ErrNotRevertible if the changes
+	// needed cannot be reverted.
+	Snapshot(context.Context, Scope) (ResourceSnapshot, error)
+}
+
+// ResourceSnapshot is the state of a resource recorded before changing it.
+type ResourceSnapshot interface {
+	// Restore restores the recorded state of the resource.
+	Restore(context.Context, Scope) error
+}
+
+// checkRevertible returns an error if any of the selected nodes is not revertible.
+func (m *Manager) checkRevertible(nodes []*resourceNode) error {
+	var errs []error
+	for _, node := range nodes {
+		if !m.selected(node) {
+			continue
+		}
+		if _, ok := asResource[RevertibleResource](node.resource); !ok {
+			errs = append(errs, fmt.Errorf("transactional mode not supported by %s: %w", node.resource, ErrNotRevertible))
+		}
+	}
+	return newApplyError(errs)
+}
+
+// snapshotNode is a snapshot of a node taken during a transaction.
+type snapshotNode struct {
+	node     *resourceNode
+	snapshot ResourceSnapshot
+}
+
+// executeTransaction applies the nodes sequentially, taking snapshots of the
+// resources before changing them. When a resource fails, or the context is done,
+// the following resources are skipped, and the snapshots are restored in reverse
+// order. It returns the results of the nodes, followed by the results of the
+// reverted resources, if any, and true if the transaction was reverted. If the
+// context is done, its error is also returned.
+func (m *Manager) executeTransaction(ctx context.Context, nodes []*resourceNode, plan nodePlanner) ([]*ApplyResult, bool, error) {
+	results := make([]*ApplyResult, len(nodes))
+	var snapshots []snapshotNode
+	var interrupted error
+	failed := -1
+	for i, node := range nodes {
+		if err := ctx.Err(); err != nil {
+			interrupted = err
+			failed = i
+			break
+		}
+
+		action := plan(node)
+		if action.err == nil && (action.action == ActionCreate || action.action == ActionUpdate) {
+			snapshot, err := m.snapshot(ctx, node)
+			if err != nil {
+				results[i] = &ApplyResult{
+					action:   action.action,
+					resource: node.resource,
+					module:   node.module,
+					started:  time.Now(),
+					err:      fmt.Errorf("failed to record state: %w", err),
+				}
+				failed = i
+				break
+			}
+			snapshots = append(snapshots, snapshotNode{node: node, snapshot: snapshot})
+		}
+
+		results[i] = m.applyPlannedAction(ctx, action)
+		if results[i] != nil && results[i].err != nil {
+			failed = i
+			break
+		}
+	}
+	if failed < 0 {
+		return results, false, nil
+	}
+
+	for i := failed + 1; i < len(nodes); i++ {
+		results[i] = &ApplyResult{
+			action:   ActionSkip,
+			resource: nodes[i].resource,
+			module:   nodes[i].module,
+			reason:   "transaction reverted",
+			started:  time.Now(),
+		}
+	}
+
+	// Revert even if the context is done.
+	revertCtx := context.WithoutCancel(ctx)
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		node := snapshots[i].node
+		result := &ApplyResult{
+			action:   ActionRevert,
+			resource: node.resource,
+			module:   node.module,
+			started:  time.Now(),
+		}
+		if err := snapshots[i].snapshot.Restore(revertCtx, m.nodeScope(node)); err != nil {
+			result.err = fmt.Errorf("failed to revert: %w", err)
+		}
+		result.duration = time.Since(result.started)
+		results = append(results, result)
+	}
+	return results, true, interrupted
+}
+
+// snapshot takes a snapshot of the resource of a node.
+func (m *Manager) snapshot(ctx context.Context, node *resourceNode) (ResourceSnapshot, error) {
+	revertible, ok := asResource[RevertibleResource](node.resource)
+	if !ok {
+		return nil, ErrNotRevertible
+	}
+	return revertible.Snapshot(ctx, m.nodeScope(node))
+}
+
+// pathSnapshot is the state of a path in the file system. It can restore files,
+// empty directories and symbolic links, and it can remove files and directories
+// that didn't exist.
+type pathSnapshot struct {
+	fsys WritableFS
+	path string
+
+	// missing is the topmost directory or file that didn't exist in the path.
+	missing string
+
+	info    fs.FileInfo
+	content []byte
+	target  string
+}
+
+// snapshotPath records the state of a path in a file system. Directories are only
+// recorded if they are empty, or if they are not going to be removed.
+func snapshotPath(fsys WritableFS, path string, removesDirectory bool) (*pathSnapshot, error) {
+	snapshot := pathSnapshot{fsys: fsys, path: path}
+	info, err := fsys.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		snapshot.missing = path
+		for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if _, err := fsys.Lstat(dir); err == nil {
+				break
+			}
+			snapshot.missing = dir
+		}
+		return &snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.info = info
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		snapshot.target, err = fsys.Readlink(path)
+	case info.IsDir():
+		if removesDirectory {
+			entries, err := fsys.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			if len(entries) > 0 {
+				return nil, fmt.Errorf("directory %s is not empty: %w", path, ErrNotRevertible)
+			}
+		}
+	case info.Mode().IsRegular():
+		snapshot.content, err = readFile(fsys, path)
+	default:
+		return nil, fmt.Errorf("unsupported file type %s in %s: %w", info.Mode().Type(), path, ErrNotRevertible)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Restore restores the recorded state of the path.
+func (s *pathSnapshot) Restore(context.Context, Scope) error {
+	if s.info == nil {
+		return s.fsys.RemoveAll(s.missing)
+	}
+
+	current, err := s.fsys.Lstat(s.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case s.info.IsDir() && current.IsDir():
+	default:
+		if err := s.fsys.RemoveAll(s.path); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case s.info.Mode()&fs.ModeSymlink != 0:
+		return s.fsys.Symlink(s.target, s.path)
+	case s.info.IsDir():
+		err = s.fsys.Mkdir(s.path, s.info.Mode().Perm())
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	default:
+		err = writeFile(s.fsys, s.path, s.content, s.info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+	}
+
+	if uid, gid, found := fileOwner(s.info); found {
+		if err := s.fsys.Lchown(s.path, uid, gid); err != nil {
+			return err
+		}
+	}
+	return s.fsys.Chmod(s.path, s.info.Mode().Perm())
+}
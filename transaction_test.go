@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionReverted(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.SetTransactional(true)
+
+	existing := filepath.Join(provider.Prefix, "existing.txt")
+	err := os.WriteFile(existing, []byte("old"), 0600)
+	require.NoError(t, err)
+	require.NoError(t, os.Chmod(existing, 0600))
+
+	failingContent := func(context.Context, Scope, io.Writer) error {
+		return errors.New("cannot obtain content")
+	}
+	handler := &Handler{
+		Name: "restart",
+		Run: func(context.Context, Scope) error {
+			t.Error("handlers should not run if the transaction is reverted")
+			return nil
+		},
+	}
+	resources := Resources{
+		&File{Path: "/existing.txt", Content: FileContentLiteral("new"), Mode: FileMode(0644), Notify: []*Handler{handler}},
+		&File{Path: "/new/sub/file.txt", CreateParent: true, Content: FileContentLiteral("new")},
+		&File{Path: "/failing.txt", Content: failingContent},
+		&File{Path: "/notapplied.txt"},
+	}
+
+	results, err := manager.Apply(resources)
+	assert.Error(t, err)
+
+	expected := []struct {
+		action   string
+		resource Resource
+		failed   bool
+	}{
+		{ActionUpdate, resources[0], false},
+		{ActionCreate, resources[1], false},
+		{ActionCreate, resources[2], true},
+		{ActionSkip, resources[3], false},
+		{ActionRevert, resources[2], false},
+		{ActionRevert, resources[1], false},
+		{ActionRevert, resources[0], false},
+	}
+	require.Len(t, results, len(expected))
+	for i, e := range expected {
+		assert.Equal(t, e.action, results[i].Action(), i)
+		assert.Equal(t, e.resource, results[i].Resource(), i)
+		assert.Equal(t, e.failed, results[i].Err() != nil, i)
+	}
+
+	d, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(d))
+	info, err := os.Stat(existing)
+	require.NoError(t, err)
+	assertEqualFileMode(t, fs.FileMode(0600), info.Mode())
+
+	for _, path := range []string{"new", "failing.txt", "notapplied.txt"} {
+		_, err = os.Lstat(filepath.Join(provider.Prefix, path))
+		assert.ErrorIs(t, err, fs.ErrNotExist, path)
+	}
+}
+
+func TestTransactionSucceeded(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.SetTransactional(true)
+
+	notified := false
+	resources := Resources{
+		&File{Path: "/dir", Directory: true},
+		&Symlink{Path: "/link", Target: "dir"},
+		&File{Path: "/dir/file.txt", Content: FileContentLiteral("foo"), Notify: []*Handler{{
+			Name: "notified",
+			Run: func(context.Context, Scope) error {
+				notified = true
+				return nil
+			},
+		}}},
+	}
+
+	results, err := manager.Apply(resources)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.Equal(t, ActionRun, results[3].Action())
+	assert.True(t, notified)
+	assert.FileExists(t, filepath.Join(provider.Prefix, "link", "file.txt"))
+}
+
+func TestTransactionNotRevertible(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.SetTransactional(true)
+
+	dummy := &dummyResource{absent: true}
+	resources := Resources{
+		&File{Path: "/file.txt"},
+		dummy,
+	}
+	results, err := manager.Apply(resources)
+	assert.Empty(t, results)
+	assert.ErrorIs(t, err, ErrNotRevertible)
+	assert.ErrorContains(t, err, "transactional mode not supported by")
+	assert.Zero(t, dummy.created)
+	assert.NoFileExists(t, filepath.Join(provider.Prefix, "file.txt"))
+}
+
+func TestTransactionNotEmptyDirectory(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+	manager.SetTransactional(true)
+
+	err := os.MkdirAll(filepath.Join(provider.Prefix, "dir"), 0755)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(provider.Prefix, "dir", "file.txt"), []byte("foo"), 0644)
+	require.NoError(t, err)
+
+	resources := Resources{
+		&File{Path: "/created.txt"},
+		&File{Path: "/dir", Absent: true, Force: true},
+	}
+	results, err := manager.Apply(resources)
+	assert.ErrorIs(t, err, ErrNotRevertible)
+	require.Len(t, results, 3)
+	assert.Equal(t, ActionCreate, results[0].Action())
+	assert.ErrorContains(t, results[1].Err(), "failed to record state")
+	assert.Equal(t, ActionRevert, results[2].Action())
+
+	assert.NoFileExists(t, filepath.Join(provider.Prefix, "created.txt"))
+	assert.FileExists(t, filepath.Join(provider.Prefix, "dir", "file.txt"))
+}
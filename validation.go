@@ -0,0 +1,53 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"fmt"
+)
+
+// ValidatableResource is implemented by resources that can check if their
+// definition is valid. Resources are validated before applying any of them,
+// so no change is made if any resource is not valid.
+type ValidatableResource interface {
+	Resource
+
+	// Validate returns an error if the definition of the resource is not valid
+	// in the given scope.
+	Validate(Scope) error
+}
+
+// validateNodes validates the resources of the given nodes. It returns an error
+// wrapping the errors of all the resources that are not valid. Only selected nodes
+// are validated.
+func (m *Manager) validateNodes(nodes []*resourceNode) error {
+	var errors []error
+	for _, node := range nodes {
+		if !m.selected(node) {
+			continue
+		}
+		validatable, ok := asResource[ValidatableResource](node.resource)
+		if !ok {
+			continue
+		}
+		if err := validatable.Validate(m.nodeScope(node)); err != nil {
+			errors = append(errors, fmt.Errorf("invalid resource %s: %w", node.resource, err))
+		}
+	}
+	return newApplyError(errors)
+}
@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationBeforeApply(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider("file", &provider)
+
+	valid := &File{Path: "/valid.txt"}
+	resources := Resources{
+		valid,
+		&File{Path: "/dir", Directory: true, Content: FileContentLiteral("foo")},
+		&File{Path: "/absent", Absent: true, CreateParent: true},
+		&File{Path: ""},
+	}
+
+	results, err := manager.Apply(resources)
+	assert.Empty(t, results)
+	var applyErr *applyError
+	require.ErrorAs(t, err, &applyErr)
+	assert.Len(t, applyErr.Unwrap(), 3)
+	assert.ErrorContains(t, err, "there were 3 errors")
+	assert.ErrorContains(t, applyErr.Unwrap()[0], "invalid resource [File::/dir]: directories cannot have content")
+	assert.ErrorContains(t, applyErr.Unwrap()[1], "absent files cannot create their parent directories")
+	assert.ErrorContains(t, applyErr.Unwrap()[2], "path is required")
+
+	_, err = os.Stat(filepath.Join(provider.Prefix, valid.Path))
+	assert.ErrorIs(t, err, os.ErrNotExist, "no resource should be applied")
+
+	plan, err := manager.Plan(context.Background(), resources)
+	assert.Error(t, err)
+	assert.Empty(t, plan)
+}
+
+func TestValidationSkipped(t *testing.T) {
+	manager := NewManager()
+	manager.AddFacter(StaticFacter{"enabled": "false"})
+	manager.SetFilter(func(resource Resource, _ string) bool {
+		validated, ok := asResource[*validatedResource](resource)
+		return !ok || validated.name != "excluded"
+	})
+
+	resources := Resources{
+		&validatedResource{name: "valid"},
+		&validatedResource{name: "excluded", err: errors.New("invalid")},
+		When(FactEquals("enabled", "true"), &validatedResource{name: "disabled", err: errors.New("invalid")}),
+	}
+	_, err := manager.Apply(resources)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resources[0].(*validatedResource).created)
+}
+
+func TestFileValidate(t *testing.T) {
+	manager := NewManager()
+	manager.RegisterProvider("file", &FileProvider{})
+
+	cases := []struct {
+		title    string
+		file     File
+		expected string
+	}{
+		{
+			title: "valid",
+			file:  File{Path: "/foo", Content: FileContentLiteral("foo"), Checksum: SHA256("2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")},
+		},
+		{
+			title:    "unknown provider",
+			file:     File{Provider: "other", Path: "/foo"},
+			expected: `file provider "other" not found`,
+		},
+		{
+			title:    "directory with checksum",
+			file:     File{Path: "/foo", Directory: true, MD5: "acbd18db4cc2f85cedef654fccc4a4d8"},
+			expected: "directories cannot have checksum",
+		},
+		{
+			title:    "absent with content",
+			file:     File{Path: "/foo", Absent: true, Content: FileContentLiteral("foo")},
+			expected: "absent files cannot have content",
+		},
+		{
+			title:    "invalid checksum",
+			file:     File{Path: "/foo", Content: FileContentLiteral("foo"), Checksum: SHA256("foo")},
+			expected: "invalid sha256 checksum",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.title, func(t *testing.T) {
+			err := c.file.Validate(manager)
+			if c.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, c.expected)
+			}
+		})
+	}
+}
+
+// validatedResource is a resource that fails validation if it has an error.
+type validatedResource struct {
+	dummyResource
+	name string
+	err  error
+}
+
+func (r *validatedResource) Validate(Scope) error { return r.err }
+func (r *validatedResource) Get(ctx context.Context, scope Scope) (ResourceState, error) {
+	return &dummyResourceState{absent: true}, nil
+}
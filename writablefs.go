@@ -0,0 +1,466 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrPathEscapes is returned when a path managed by a file provider is out of
+// its prefix.
+var ErrPathEscapes = errors.New("path escapes from prefix")
+
+// WritableFS is a file system where files can be managed by file providers.
+// Names are paths relative to the root of the file system, they are cleaned
+// before being used, so a leading slash is also relative to the root.
+type WritableFS interface {
+	// Stat returns information about the named file, following links.
+	Stat(name string) (fs.FileInfo, error)
+	// Lstat returns information about the named file, without following links.
+	Lstat(name string) (fs.FileInfo, error)
+	// Open opens the named file for reading.
+	Open(name string) (fs.File, error)
+	// OpenFile opens the named file with the given flags, as os.OpenFile.
+	OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error)
+	// CreateTemp creates a new temporary file in the directory, as os.CreateTemp.
+	CreateTemp(dir, pattern string) (WritableFile, error)
+	// ReadDir returns the entries of the named directory, sorted by name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Readlink returns the target of the named link.
+	Readlink(name string) (string, error)
+	// Mkdir creates a directory.
+	Mkdir(name string, perm fs.FileMode) error
+	// MkdirAll creates a directory and any missing parent.
+	MkdirAll(name string, perm fs.FileMode) error
+	// Remove removes a file or an empty directory.
+	Remove(name string) error
+	// RemoveAll removes a path and any children it contains. It doesn't fail
+	// if the path doesn't exist.
+	RemoveAll(name string) error
+	// Rename renames a file, replacing the new one if it exists.
+	Rename(oldname, newname string) error
+	// Symlink creates a link with the new name pointing to the old name.
+	Symlink(oldname, newname string) error
+	// Chmod changes the mode of the named file.
+	Chmod(name string, mode fs.FileMode) error
+	// Lchown changes the owner and the group of the named file, without
+	// following links. An id of -1 keeps the current value.
+	Lchown(name string, uid, gid int) error
+	// SyncDir flushes the entries of the named directory to storage.
+	SyncDir(name string) error
+}
+
+// WritableFile is a file opened for writing in a writable file system.
+type WritableFile interface {
+	io.WriteCloser
+
+	// Name returns the name of the file in the file system.
+	Name() string
+	// Stat returns information about the file.
+	Stat() (fs.FileInfo, error)
+	// Sync flushes the content of the file to storage.
+	Sync() error
+	// Chmod changes the mode of the file.
+	Chmod(mode fs.FileMode) error
+	// Chown changes the owner and the group of the file.
+	Chown(uid, gid int) error
+}
+
+// osFS is the file system of the operating system.
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &osFile{File: file, name: name}, nil
+}
+
+func (osFS) CreateTemp(dir, pattern string) (WritableFile, error) {
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &osFile{File: file, name: file.Name()}, nil
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (osFS) Mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (osFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (osFS) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (osFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (osFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (osFS) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+func (osFS) SyncDir(name string) error {
+	return syncDir(name)
+}
+
+// rootFS is the file system of the operating system confined to a directory.
+// Any path out of the directory, including the ones reached through links, is
+// rejected with ErrPathEscapes.
+type rootFS struct {
+	prefix string
+}
+
+// rootName returns the name of a path relative to the root. A leading separator
+// is also relative to the root. It fails if the path escapes the root lexically.
+func rootName(prefix, op, name string) (string, error) {
+	rel := name
+	for len(rel) > 0 && os.IsPathSeparator(rel[0]) {
+		rel = rel[1:]
+	}
+	if rel == "" {
+		return ".", nil
+	}
+	if !filepath.IsLocal(rel) {
+		return "", &fs.PathError{Op: op, Path: name, Err: escapeError(prefix)}
+	}
+	return rel, nil
+}
+
+func escapeError(prefix string) error {
+	return fmt.Errorf("%w %s", ErrPathEscapes, prefix)
+}
+
+// do calls the function with the root and the relative name of the path.
+func (f rootFS) do(op, name string, fn func(root *os.Root, name string) error) error {
+	rel, err := rootName(f.prefix, op, name)
+	if err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(f.prefix)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return f.wrapErr(op, rel, fn(root, rel))
+}
+
+// nofollowOps are the operations that don't follow links in the last element
+// of the path.
+var nofollowOps = map[string]bool{
+	"lstat":     true,
+	"readlink":  true,
+	"remove":    true,
+	"removeall": true,
+	"rename":    true,
+	"symlink":   true,
+	"lchown":    true,
+}
+
+// wrapErr replaces errors of paths escaping the root with ErrPathEscapes. The
+// error returned by os.Root in this case is not exported, so when an operation
+// fails, the path is resolved to check if it escapes from the root.
+func (f rootFS) wrapErr(op, rel string, err error) error {
+	if err == nil || !escapesRoot(f.prefix, rel, !nofollowOps[op]) {
+		return err
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: pathErr.Path, Err: escapeError(f.prefix)}
+	}
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return &os.LinkError{Op: linkErr.Op, Old: linkErr.Old, New: linkErr.New, Err: escapeError(f.prefix)}
+	}
+	return err
+}
+
+// maxRootLinks is the maximum number of links followed when resolving a path in
+// a root.
+const maxRootLinks = 255
+
+// escapesRoot returns true if the path, relative to the root, escapes from it,
+// as os.Root resolves paths. Links are followed, and their targets are resolved
+// in place. Absolute targets always escape. Links in the last element of the
+// path are only followed if followLast is true.
+func escapesRoot(root, rel string, followLast bool) bool {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	var resolved []string
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return true
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		path := filepath.Join(root, filepath.Join(resolved...), part)
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 || len(parts) == 0 && !followLast {
+			resolved = append(resolved, part)
+			continue
+		}
+		links++
+		if links > maxRootLinks {
+			return false
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return false
+		}
+		if filepath.IsAbs(target) {
+			return true
+		}
+		parts = append(strings.Split(filepath.ToSlash(target), "/"), parts...)
+	}
+	return false
+}
+
+func (f rootFS) Stat(name string) (info fs.FileInfo, err error) {
+	err = f.do("stat", name, func(root *os.Root, name string) error {
+		info, err = root.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+func (f rootFS) Lstat(name string) (info fs.FileInfo, err error) {
+	err = f.do("lstat", name, func(root *os.Root, name string) error {
+		info, err = root.Lstat(name)
+		return err
+	})
+	return info, err
+}
+
+func (f rootFS) Open(name string) (file fs.File, err error) {
+	err = f.do("open", name, func(root *os.Root, name string) error {
+		file, err = root.Open(name)
+		return err
+	})
+	return file, err
+}
+
+func (f rootFS) OpenFile(name string, flag int, perm fs.FileMode) (WritableFile, error) {
+	var file *os.File
+	err := f.do("open", name, func(root *os.Root, rel string) (err error) {
+		file, err = root.OpenFile(rel, flag, perm)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &osFile{File: file, name: name}, nil
+}
+
+func (f rootFS) CreateTemp(dir, pattern string) (WritableFile, error) {
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		file, err := f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) && try < 10000 {
+			continue
+		}
+		return file, err
+	}
+}
+
+func (f rootFS) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	err = f.do("readdir", name, func(root *os.Root, name string) error {
+		dir, err := root.Open(name)
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+		entries, err = dir.ReadDir(-1)
+		return err
+	})
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, err
+}
+
+func (f rootFS) Readlink(name string) (target string, err error) {
+	err = f.do("readlink", name, func(root *os.Root, name string) error {
+		target, err = root.Readlink(name)
+		return err
+	})
+	return target, err
+}
+
+func (f rootFS) Mkdir(name string, perm fs.FileMode) error {
+	return f.do("mkdir", name, func(root *os.Root, name string) error {
+		return root.Mkdir(name, perm)
+	})
+}
+
+// MkdirAll creates a directory and any missing parent, including the prefix
+// if it doesn't exist.
+func (f rootFS) MkdirAll(name string, perm fs.FileMode) error {
+	if _, err := rootName(f.prefix, "mkdir", name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.prefix, perm); err != nil {
+		return err
+	}
+	return f.do("mkdir", name, func(root *os.Root, name string) error {
+		return root.MkdirAll(name, perm)
+	})
+}
+
+func (f rootFS) Remove(name string) error {
+	return f.do("remove", name, func(root *os.Root, name string) error {
+		return root.Remove(name)
+	})
+}
+
+func (f rootFS) RemoveAll(name string) error {
+	return f.do("removeall", name, func(root *os.Root, name string) error {
+		return root.RemoveAll(name)
+	})
+}
+
+func (f rootFS) Rename(oldname, newname string) error {
+	newRel, err := rootName(f.prefix, "rename", newname)
+	if err != nil {
+		return err
+	}
+	return f.do("rename", oldname, func(root *os.Root, oldRel string) error {
+		return f.wrapErr("rename", newRel, root.Rename(oldRel, newRel))
+	})
+}
+
+func (f rootFS) Symlink(oldname, newname string) error {
+	return f.do("symlink", newname, func(root *os.Root, name string) error {
+		return root.Symlink(oldname, name)
+	})
+}
+
+func (f rootFS) Chmod(name string, mode fs.FileMode) error {
+	return f.do("chmod", name, func(root *os.Root, name string) error {
+		return root.Chmod(name, mode)
+	})
+}
+
+func (f rootFS) Lchown(name string, uid, gid int) error {
+	return f.do("lchown", name, func(root *os.Root, name string) error {
+		return root.Lchown(name, uid, gid)
+	})
+}
+
+func (f rootFS) SyncDir(name string) error {
+	return f.do("sync", name, func(root *os.Root, name string) error {
+		dir, err := root.Open(name)
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+		return dir.Sync()
+	})
+}
+
+// osFile is a file opened in the file system of the operating system, named
+// as in the file system it was opened in.
+type osFile struct {
+	*os.File
+	name string
+}
+
+func (f *osFile) Name() string {
+	return f.name
+}
+
+// readFile reads the content of the named file.
+func readFile(fsys WritableFS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// writeFile writes the content to the named file, creating it with the given
+// permissions if needed.
+func writeFile(fsys WritableFS, name string, data []byte, perm fs.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
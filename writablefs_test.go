@@ -0,0 +1,230 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package resource
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProviderPathEscapes(t *testing.T) {
+	dir := t.TempDir()
+	provider := FileProvider{
+		Prefix: filepath.Join(dir, "prefix"),
+	}
+	require.NoError(t, os.Mkdir(provider.Prefix, 0755))
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resources := Resources{
+		&File{Path: "../outside.txt", Content: FileContentLiteral("content")},
+		&File{Path: "/sub/../../other.txt", Content: FileContentLiteral("content")},
+		&Symlink{Path: "../link", Target: "/etc/passwd"},
+	}
+	_, err := manager.Apply(resources)
+	var applyErr *applyError
+	require.ErrorAs(t, err, &applyErr)
+	require.Len(t, applyErr.Unwrap(), 3)
+	for _, err := range applyErr.Unwrap() {
+		assert.ErrorIs(t, err, ErrPathEscapes)
+	}
+	assert.ErrorContains(t, applyErr.Unwrap()[0], "invalid resource [File::../outside.txt]: access ../outside.txt: path escapes from prefix "+provider.Prefix)
+
+	for _, name := range []string{"outside.txt", "other.txt", "link"} {
+		_, err = os.Lstat(filepath.Join(dir, name))
+		assert.ErrorIs(t, err, fs.ErrNotExist, name)
+	}
+}
+
+func TestFileProviderPathInPrefix(t *testing.T) {
+	provider := FileProvider{
+		Prefix: t.TempDir(),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resources := Resources{
+		&File{Path: "/sub", Directory: true},
+		&File{Path: "/sub/../file.txt", Content: FileContentLiteral("content")},
+	}
+	_, err := manager.Apply(resources)
+	require.NoError(t, err)
+
+	d, err := os.ReadFile(filepath.Join(provider.Prefix, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(d))
+}
+
+func TestFileProviderLinkEscapes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating links requires privileges on windows")
+	}
+
+	dir := t.TempDir()
+	provider := FileProvider{
+		Prefix: filepath.Join(dir, "prefix"),
+	}
+	require.NoError(t, os.Mkdir(provider.Prefix, 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "outside"), 0755))
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	// Links pointing out of the prefix can be managed, but not followed.
+	link := Symlink{Path: "/link", Target: filepath.Join(dir, "outside")}
+	_, err := manager.Apply(Resources{&link})
+	require.NoError(t, err)
+
+	resources := Resources{
+		&File{Path: "/link/file.txt", Content: FileContentLiteral("content")},
+	}
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrPathEscapes)
+
+	_, err = os.Lstat(filepath.Join(dir, "outside", "file.txt"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Relative links going up are also detected.
+	require.NoError(t, os.Symlink("../outside", filepath.Join(provider.Prefix, "relative")))
+	resources = Resources{
+		&File{Path: "/relative/file.txt", Content: FileContentLiteral("content")},
+	}
+	_, err = manager.Apply(resources)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrPathEscapes)
+}
+
+func TestRootName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+		escapes  bool
+	}{
+		{name: "", expected: "."},
+		{name: "/", expected: "."},
+		{name: "file.txt", expected: "file.txt"},
+		{name: "/etc/file.txt", expected: filepath.FromSlash("etc/file.txt")},
+		{name: "//etc/file.txt", expected: filepath.FromSlash("etc/file.txt")},
+		{name: "etc/../file.txt", expected: filepath.FromSlash("etc/../file.txt")},
+		{name: "..", escapes: true},
+		{name: "../file.txt", escapes: true},
+		{name: "/etc/../../file.txt", escapes: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rel, err := rootName("/prefix", "open", filepath.FromSlash(c.name))
+			if c.escapes {
+				assert.ErrorIs(t, err, ErrPathEscapes)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, rel)
+		})
+	}
+}
+
+func TestFileProviderMissingPrefix(t *testing.T) {
+	provider := FileProvider{
+		Prefix: filepath.Join(t.TempDir(), "profiles", "default"),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resources := Resources{
+		&File{Path: "a/b.txt", CreateParent: true, Content: FileContentLiteral("content")},
+	}
+	result, err := manager.Apply(resources)
+	t.Log(result)
+	require.NoError(t, err)
+
+	d, err := os.ReadFile(filepath.Join(provider.Prefix, "a", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(d))
+
+	// Paths out of the prefix are still rejected.
+	resources = Resources{
+		&File{Path: "../../c.txt", CreateParent: true, Content: FileContentLiteral("content")},
+	}
+	_, err = manager.Apply(resources)
+	assert.ErrorIs(t, err, ErrPathEscapes)
+}
+
+func TestFileProviderMissingPrefixWithoutParent(t *testing.T) {
+	provider := FileProvider{
+		Prefix: filepath.Join(t.TempDir(), "missing"),
+	}
+	manager := NewManager()
+	manager.RegisterProvider(defaultFileProviderName, &provider)
+
+	resource := File{Path: "file.txt", Content: FileContentLiteral("content")}
+	state, err := resource.Get(context.Background(), manager)
+	require.NoError(t, err)
+	assert.False(t, state.Found(context.Background()))
+
+	_, err = manager.Apply(Resources{&resource})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestRootFSLinkEscapes(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "prefix")
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.Mkdir(prefix, 0755))
+	require.NoError(t, os.Mkdir(outside, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "file.txt"), []byte("outside"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(prefix, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(prefix, "file.txt"), []byte("inside"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(prefix, "absolute")))
+	require.NoError(t, os.Symlink("../outside", filepath.Join(prefix, "relative")))
+	require.NoError(t, os.Symlink("../outside/new.txt", filepath.Join(prefix, "dangling")))
+	require.NoError(t, os.Symlink("dir", filepath.Join(prefix, "inside")))
+
+	fsys := rootFS{prefix: prefix}
+	escapes := map[string]error{}
+	_, escapes["stat absolute"] = fsys.Stat("absolute/file.txt")
+	_, escapes["stat relative"] = fsys.Stat("relative")
+	_, escapes["stat up from link"] = fsys.Stat("inside/../../outside")
+	_, escapes["open"] = fsys.Open("relative/file.txt")
+	_, escapes["create dangling"] = fsys.OpenFile("dangling", os.O_WRONLY|os.O_CREATE, 0644)
+	escapes["mkdir"] = fsys.Mkdir("relative/dir", 0755)
+	escapes["remove"] = fsys.Remove("relative/file.txt")
+	escapes["rename"] = fsys.Rename("file.txt", "relative/file.txt")
+	for name, err := range escapes {
+		assert.ErrorIs(t, err, ErrPathEscapes, name)
+	}
+	assert.NoFileExists(t, filepath.Join(outside, "new.txt"))
+	assert.FileExists(t, filepath.Join(outside, "file.txt"))
+
+	// Links themselves, or links inside the prefix, can be used.
+	_, err := fsys.Lstat("relative")
+	assert.NoError(t, err)
+	_, err = fsys.Stat("inside")
+	assert.NoError(t, err)
+	_, err = fsys.Stat("inside/missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.NotErrorIs(t, err, ErrPathEscapes)
+	assert.NoError(t, fsys.Remove("absolute"))
+}